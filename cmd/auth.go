@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/Mi7teR/aggregator/internal/auth"
+)
+
+// authenticatorFromEnv builds the api authenticator from API_KEYS,
+// API_KEYS_FILE and JWT_JWKS_FILE. Authentication stays disabled when none
+// of them is set.
+func authenticatorFromEnv() (*auth.Authenticator, error) {
+	keys, err := auth.ParseKeys(os.Getenv("API_KEYS"))
+	if err != nil {
+		return nil, fmt.Errorf("api keys: %w", err)
+	}
+
+	opts := []auth.Option{auth.WithKeys(keys)}
+
+	if path := os.Getenv("API_KEYS_FILE"); path != "" {
+		fileKeys, errKeys := auth.LoadKeysFile(path)
+		if errKeys != nil {
+			return nil, errKeys
+		}
+
+		opts = append(opts, auth.WithKeys(fileKeys))
+	}
+
+	if path := os.Getenv("JWT_JWKS_FILE"); path != "" {
+		jwks, errJWKS := auth.LoadJWKS(path)
+		if errJWKS != nil {
+			return nil, errJWKS
+		}
+
+		verifier := auth.NewJWTVerifier(jwks, os.Getenv("JWT_ISSUER"), os.Getenv("JWT_AUDIENCE"))
+		opts = append(opts, auth.WithJWT(verifier))
+	}
+
+	return auth.New(opts...), nil
+}
+
+// adminAuthenticatorFromEnv builds the authenticator of the admin endpoints
+// from ADMIN_API_KEYS and ADMIN_API_KEYS_FILE. Task client credentials are
+// not accepted there.
+func adminAuthenticatorFromEnv() (*auth.Authenticator, error) {
+	keys, err := auth.ParseKeys(os.Getenv("ADMIN_API_KEYS"))
+	if err != nil {
+		return nil, fmt.Errorf("admin api keys: %w", err)
+	}
+
+	opts := []auth.Option{auth.WithKeys(keys)}
+
+	if path := os.Getenv("ADMIN_API_KEYS_FILE"); path != "" {
+		fileKeys, errKeys := auth.LoadKeysFile(path)
+		if errKeys != nil {
+			return nil, errKeys
+		}
+
+		opts = append(opts, auth.WithKeys(fileKeys))
+	}
+
+	return auth.New(opts...), nil
+}
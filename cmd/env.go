@@ -0,0 +1,52 @@
+package main
+
+import (
+	"os"
+	"strconv"
+	"time"
+)
+
+func intFromEnv(name string, def int) (int, error) {
+	v := os.Getenv(name)
+	if v == "" {
+		return def, nil
+	}
+
+	return strconv.Atoi(v)
+}
+
+func int64FromEnv(name string, def int64) (int64, error) {
+	v := os.Getenv(name)
+	if v == "" {
+		return def, nil
+	}
+
+	return strconv.ParseInt(v, 10, 64)
+}
+
+func durationFromEnv(name string, def time.Duration) (time.Duration, error) {
+	v := os.Getenv(name)
+	if v == "" {
+		return def, nil
+	}
+
+	return time.ParseDuration(v)
+}
+
+func boolFromEnv(name string, def bool) (bool, error) {
+	v := os.Getenv(name)
+	if v == "" {
+		return def, nil
+	}
+
+	return strconv.ParseBool(v)
+}
+
+func floatFromEnv(name string, def float64) (float64, error) {
+	v := os.Getenv(name)
+	if v == "" {
+		return def, nil
+	}
+
+	return strconv.ParseFloat(v, 64)
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/Mi7teR/aggregator/internal/policy"
+	"github.com/Mi7teR/aggregator/internal/task/service"
+)
+
+// hostLimitsFromEnv reads the default per host limit from
+// HOST_MAX_CONCURRENCY, HOST_RPS and HOST_BURST, and per host overrides
+// from HOST_LIMITS.
+func hostLimitsFromEnv() (service.HostLimit, map[string]service.HostLimit, error) {
+	var (
+		limit service.HostLimit
+		err   error
+	)
+
+	if limit.MaxConcurrent, err = intFromEnv("HOST_MAX_CONCURRENCY", 0); err != nil {
+		return limit, nil, fmt.Errorf("host max concurrency: %w", err)
+	}
+
+	if limit.RPS, err = floatFromEnv("HOST_RPS", 0); err != nil {
+		return limit, nil, fmt.Errorf("host rps: %w", err)
+	}
+
+	if limit.Burst, err = intFromEnv("HOST_BURST", 0); err != nil {
+		return limit, nil, fmt.Errorf("host burst: %w", err)
+	}
+
+	overrides, err := service.ParseHostLimits(policy.SplitList(os.Getenv("HOST_LIMITS")))
+	if err != nil {
+		return limit, nil, err
+	}
+
+	return limit, overrides, nil
+}
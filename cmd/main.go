@@ -3,55 +3,212 @@ package main
 import (
 	"context"
 	"fmt"
-	"log"
+	"log/slog"
+	"math"
 	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
 
+	"github.com/Mi7teR/aggregator/internal/logger"
+	"github.com/Mi7teR/aggregator/internal/metrics"
+	"github.com/Mi7teR/aggregator/internal/ratelimit"
 	"github.com/Mi7teR/aggregator/internal/task/delivery/api"
 	"github.com/Mi7teR/aggregator/internal/task/repository"
 	"github.com/Mi7teR/aggregator/internal/task/service"
 )
 
 func main() {
+	l, err := logger.New(os.Stdout, os.Getenv("LOG_LEVEL"), os.Getenv("LOG_FORMAT"))
+	if err != nil {
+		fatal("cant configure logger", err)
+	}
+	slog.SetDefault(l)
+
 	timeoutENV := os.Getenv("TIMEOUT")
 	httpPortENV := os.Getenv("HTTP_PORT")
 
 	timeout, err := time.ParseDuration(timeoutENV)
 	if err != nil {
-		log.Fatalln(fmt.Errorf("cant parse timeout %w", err))
+		fatal("cant parse timeout", err)
+	}
+
+	maxTimeout, err := durationFromEnv("MAX_TIMEOUT", timeout)
+	if err != nil {
+		fatal("cant parse max timeout", err)
+	}
+
+	maxBodySize, err := int64FromEnv("MAX_BODY_SIZE", service.DefaultMaxBodySize)
+	if err != nil {
+		fatal("cant parse max body size", err)
+	}
+
+	workers, err := intFromEnv("WORKERS", service.DefaultWorkers)
+	if err != nil {
+		fatal("cant parse workers", err)
+	}
+
+	queueSize, err := intFromEnv("QUEUE_SIZE", service.DefaultQueueSize)
+	if err != nil {
+		fatal("cant parse queue size", err)
+	}
+
+	callbackAttempts, err := intFromEnv("CALLBACK_MAX_ATTEMPTS", service.DefaultCallbackAttempts)
+	if err != nil {
+		fatal("cant parse callback max attempts", err)
 	}
 
-	repo := repository.NewTaskInMemoryRepository()
-	s := service.NewService(repo, timeout)
-	handler := api.NewHandler(s)
-	r := api.NewRouter(handler)
+	resultTTL, err := durationFromEnv("RESULT_TTL", 0)
+	if err != nil {
+		fatal("cant parse result ttl", err)
+	}
+
+	maxResults, err := intFromEnv("MAX_RESULTS", 0)
+	if err != nil {
+		fatal("cant parse max results", err)
+	}
+
+	janitorInterval, err := durationFromEnv("JANITOR_INTERVAL", service.DefaultJanitorInterval)
+	if err != nil {
+		fatal("cant parse janitor interval", err)
+	}
+
+	egressPolicy, err := egressPolicyFromEnv()
+	if err != nil {
+		fatal("cant parse egress policy", err)
+	}
+
+	rateLimit, err := floatFromEnv("RATE_LIMIT_RPS", 0)
+	if err != nil {
+		fatal("cant parse rate limit", err)
+	}
+
+	rateLimitBurst, err := intFromEnv("RATE_LIMIT_BURST", int(math.Ceil(rateLimit)))
+	if err != nil {
+		fatal("cant parse rate limit burst", err)
+	}
+
+	maxBatchSize, err := intFromEnv("BATCH_MAX_SIZE", api.DefaultMaxBatchSize)
+	if err != nil {
+		fatal("cant parse batch max size", err)
+	}
+
+	clientQuota, err := intFromEnv("CLIENT_MAX_INFLIGHT", 0)
+	if err != nil {
+		fatal("cant parse client max inflight", err)
+	}
+
+	hostLimit, hostOverrides, err := hostLimitsFromEnv()
+	if err != nil {
+		fatal("cant parse host limits", err)
+	}
+
+	transportConfig, err := transportConfigFromEnv()
+	if err != nil {
+		fatal("cant parse upstream transport", err)
+	}
+
+	authenticator, err := authenticatorFromEnv()
+	if err != nil {
+		fatal("cant configure authentication", err)
+	}
+
+	adminAuthenticator, err := adminAuthenticatorFromEnv()
+	if err != nil {
+		fatal("cant configure admin authentication", err)
+	}
+
+	var repo service.Repository
+	if storagePathENV := os.Getenv("STORAGE_PATH"); storagePathENV != "" {
+		boltRepo, errRepo := repository.NewTaskBoltRepository(storagePathENV)
+		if errRepo != nil {
+			fatal("cant open storage", errRepo)
+		}
+		defer boltRepo.Close()
+
+		repo = boltRepo
+	} else {
+		repo = repository.NewTaskInMemoryRepository()
+	}
+
+	m := metrics.NewPrometheus()
+
+	s := service.NewService(
+		repo,
+		timeout,
+		service.WithMaxBodySize(maxBodySize),
+		service.WithMaxTimeout(maxTimeout),
+		service.WithWorkers(workers),
+		service.WithQueueSize(queueSize),
+		service.WithCallbackAttempts(callbackAttempts),
+		service.WithRetention(resultTTL, maxResults),
+		service.WithJanitorInterval(janitorInterval),
+		service.WithMetrics(m),
+		service.WithEgressPolicy(egressPolicy),
+		service.WithClientQuota(clientQuota),
+		service.WithHostLimits(hostLimit, hostOverrides),
+		service.WithTransport(transportConfig),
+	)
+	m.WatchService(s)
+
+	routerOpts := []api.RouterOption{api.WithMiddleware(m.Middleware), api.WithMetricsHandler(m.Handler())}
+	if authenticator.Enabled() {
+		routerOpts = append(routerOpts, api.WithAuth(authenticator.Middleware))
+	} else {
+		slog.Warn("authentication disabled, set API_KEYS, API_KEYS_FILE or JWT_JWKS_FILE to enable it")
+	}
+	if adminAuthenticator.Enabled() {
+		routerOpts = append(routerOpts, api.WithAdminAuth(adminAuthenticator.Middleware))
+	} else {
+		slog.Info("admin endpoints disabled, set ADMIN_API_KEYS or ADMIN_API_KEYS_FILE to enable them")
+	}
+	if rateLimit > 0 && rateLimitBurst > 0 {
+		routerOpts = append(routerOpts, api.WithSubmitLimit(ratelimit.New(rateLimit, rateLimitBurst)))
+
+		// A batch is charged a token per task, so it can never be larger
+		// than the burst.
+		if maxBatchSize > rateLimitBurst {
+			slog.Warn("batch max size lowered to the rate limit burst, raise RATE_LIMIT_BURST to allow larger batches",
+				"batch_max_size", rateLimitBurst)
+			maxBatchSize = rateLimitBurst
+		}
+	}
+	r := api.NewRouter(api.NewHandler(s, api.WithMaxBatchSize(maxBatchSize)), routerOpts...)
 
 	srv := &http.Server{
 		Addr:    fmt.Sprintf(":%s", httpPortENV),
 		Handler: r,
 	}
+	srv.RegisterOnShutdown(s.CloseEvents)
 
 	done := make(chan os.Signal, 1)
 	signal.Notify(done, os.Interrupt, syscall.SIGINT, syscall.SIGTERM)
 
 	go func() {
 		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("listen: %s\n", err)
+			fatal("listen", err)
 		}
 	}()
-	log.Print("Server Started")
+	slog.Info("server started", "addr", srv.Addr)
 
 	<-done
-	log.Print("Server Stopped")
+	slog.Info("server stopping")
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
 	if err := srv.Shutdown(ctx); err != nil {
-		log.Fatalf("Server Shutdown Failed: %+v", err)
+		fatal("server shutdown failed", err)
 	}
-	log.Print("Server Exited Properly")
+
+	if err := s.Shutdown(ctx); err != nil {
+		slog.Warn("service shutdown timed out, running tasks were cancelled", "error", err)
+	}
+	slog.Info("server exited properly")
+}
+
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
 }
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/Mi7teR/aggregator/internal/policy"
+)
+
+const defaultAllowedSchemes = "http,https"
+
+// egressPolicyFromEnv builds the egress policy. Private and special purpose
+// ranges are denied unless EGRESS_ALLOW_PRIVATE is set.
+func egressPolicyFromEnv() (*policy.Policy, error) {
+	schemes := os.Getenv("EGRESS_ALLOWED_SCHEMES")
+	if schemes == "" {
+		schemes = defaultAllowedSchemes
+	}
+
+	allowPrivate, err := boolFromEnv("EGRESS_ALLOW_PRIVATE", false)
+	if err != nil {
+		return nil, fmt.Errorf("egress allow private: %w", err)
+	}
+
+	allowedNets, err := policy.ParseNets(policy.SplitList(os.Getenv("EGRESS_ALLOWED_CIDRS")))
+	if err != nil {
+		return nil, fmt.Errorf("egress allowed cidrs: %w", err)
+	}
+
+	deniedNets, err := policy.ParseNets(policy.SplitList(os.Getenv("EGRESS_DENIED_CIDRS")))
+	if err != nil {
+		return nil, fmt.Errorf("egress denied cidrs: %w", err)
+	}
+
+	if !allowPrivate {
+		deniedNets = append(deniedNets, policy.PrivateNets()...)
+	}
+
+	blockedPorts, err := policy.ParsePorts(policy.SplitList(os.Getenv("EGRESS_BLOCKED_PORTS")))
+	if err != nil {
+		return nil, fmt.Errorf("egress blocked ports: %w", err)
+	}
+
+	return &policy.Policy{
+		AllowedSchemes: policy.SplitList(schemes),
+		AllowedHosts:   policy.SplitList(os.Getenv("EGRESS_ALLOWED_HOSTS")),
+		DeniedHosts:    policy.SplitList(os.Getenv("EGRESS_DENIED_HOSTS")),
+		AllowedNets:    allowedNets,
+		DeniedNets:     deniedNets,
+		BlockedPorts:   blockedPorts,
+	}, nil
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+
+	"github.com/Mi7teR/aggregator/internal/task/service"
+)
+
+var (
+	ErrUnknownTLSVersion = errors.New("unknown tls version")
+	ErrInvalidProxyURL   = errors.New("proxy url must be absolute")
+)
+
+// transportConfigFromEnv reads the upstream transport settings from the
+// UPSTREAM_* variables.
+func transportConfigFromEnv() (service.TransportConfig, error) {
+	var (
+		cfg service.TransportConfig
+		err error
+	)
+
+	if cfg.MaxIdleConns, err = intFromEnv("UPSTREAM_MAX_IDLE_CONNS", 0); err != nil {
+		return cfg, fmt.Errorf("upstream max idle conns: %w", err)
+	}
+
+	if cfg.MaxIdleConnsPerHost, err = intFromEnv("UPSTREAM_MAX_IDLE_CONNS_PER_HOST", 0); err != nil {
+		return cfg, fmt.Errorf("upstream max idle conns per host: %w", err)
+	}
+
+	if cfg.MaxConnsPerHost, err = intFromEnv("UPSTREAM_MAX_CONNS_PER_HOST", 0); err != nil {
+		return cfg, fmt.Errorf("upstream max conns per host: %w", err)
+	}
+
+	if cfg.IdleConnTimeout, err = durationFromEnv("UPSTREAM_IDLE_CONN_TIMEOUT", 0); err != nil {
+		return cfg, fmt.Errorf("upstream idle conn timeout: %w", err)
+	}
+
+	if cfg.KeepAlive, err = durationFromEnv("UPSTREAM_KEEP_ALIVE", 0); err != nil {
+		return cfg, fmt.Errorf("upstream keep alive: %w", err)
+	}
+
+	if cfg.DisableKeepAlives, err = boolFromEnv("UPSTREAM_DISABLE_KEEP_ALIVES", false); err != nil {
+		return cfg, fmt.Errorf("upstream disable keep alives: %w", err)
+	}
+
+	if cfg.DisableHTTP2, err = boolFromEnv("UPSTREAM_DISABLE_HTTP2", false); err != nil {
+		return cfg, fmt.Errorf("upstream disable http2: %w", err)
+	}
+
+	if v := os.Getenv("UPSTREAM_TLS_MIN_VERSION"); v != "" {
+		if cfg.TLSMinVersion, err = tlsVersion(v); err != nil {
+			return cfg, err
+		}
+	}
+
+	if v := os.Getenv("UPSTREAM_PROXY_URL"); v != "" {
+		u, errURL := url.Parse(v)
+		if errURL != nil {
+			return cfg, fmt.Errorf("upstream proxy url: %w", errURL)
+		}
+
+		if !u.IsAbs() || u.Host == "" {
+			return cfg, ErrInvalidProxyURL
+		}
+
+		cfg.ProxyURL = u
+	}
+
+	return cfg, nil
+}
+
+func tlsVersion(v string) (uint16, error) {
+	switch v {
+	case "1.0":
+		return tls.VersionTLS10, nil
+	case "1.1":
+		return tls.VersionTLS11, nil
+	case "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	default:
+		return 0, fmt.Errorf("%w: %q", ErrUnknownTLSVersion, v)
+	}
+}
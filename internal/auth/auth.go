@@ -0,0 +1,133 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/Mi7teR/aggregator/internal/task/entity"
+)
+
+const (
+	headerAPIKey = "X-API-Key"
+	bearerScheme = "Bearer"
+
+	// Client ids are namespaced by the credential they come from, so a token
+	// subject can never match the name of an api key client.
+	keyClientPrefix = "key:"
+	jwtClientPrefix = "jwt:"
+)
+
+var (
+	ErrMissingCredentials = errors.New("missing credentials")
+	ErrInvalidCredentials = errors.New("invalid credentials")
+)
+
+type ctxKey struct{}
+
+// WithClientID returns a copy of ctx carrying the authenticated client id.
+func WithClientID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, ctxKey{}, id)
+}
+
+// ClientID returns the authenticated client id stored in ctx, prefixed with
+// "key:" or "jwt:" by its credential source. It is empty when
+// authentication is disabled.
+func ClientID(ctx context.Context) string {
+	id, _ := ctx.Value(ctxKey{}).(string)
+	return id
+}
+
+type Option func(a *Authenticator)
+
+// WithKeys accepts static api keys mapped to the id of the client owning
+// them.
+func WithKeys(keys map[string]string) Option {
+	return func(a *Authenticator) {
+		for key, client := range keys {
+			a.keys[sha256.Sum256([]byte(key))] = keyClientPrefix + client
+		}
+	}
+}
+
+// WithJWT accepts bearer tokens signed by one of the verifier keys, using
+// their subject as the client id.
+func WithJWT(v *JWTVerifier) Option {
+	return func(a *Authenticator) {
+		a.jwt = v
+	}
+}
+
+// Authenticator resolves the client behind a request from a static api key
+// or a signed JWT. Keys are looked up by their hash so the comparison does
+// not depend on how much of a guessed key is right.
+type Authenticator struct {
+	keys map[[sha256.Size]byte]string
+	jwt  *JWTVerifier
+}
+
+func New(opts ...Option) *Authenticator {
+	a := &Authenticator{keys: make(map[[sha256.Size]byte]string)}
+	for _, opt := range opts {
+		opt(a)
+	}
+
+	return a
+}
+
+// Enabled reports whether any credentials are configured.
+func (a *Authenticator) Enabled() bool {
+	return len(a.keys) > 0 || a.jwt != nil
+}
+
+// Authenticate returns the id of the client the request credentials belong
+// to. The token is read from the X-API-Key header or a bearer Authorization
+// header.
+func (a *Authenticator) Authenticate(r *http.Request) (string, error) {
+	token := r.Header.Get(headerAPIKey)
+	if token == "" {
+		scheme, credentials, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+		if ok && strings.EqualFold(scheme, bearerScheme) {
+			token = strings.TrimSpace(credentials)
+		}
+	}
+
+	if token == "" {
+		return "", ErrMissingCredentials
+	}
+
+	if client, ok := a.keys[sha256.Sum256([]byte(token))]; ok {
+		return client, nil
+	}
+
+	if a.jwt != nil {
+		client, err := a.jwt.Verify(token)
+		if err != nil {
+			return "", errors.Join(ErrInvalidCredentials, err)
+		}
+
+		return jwtClientPrefix + client, nil
+	}
+
+	return "", ErrInvalidCredentials
+}
+
+// Middleware rejects unauthenticated requests with 401 and stores the
+// client id in the request context for the handlers.
+func (a *Authenticator) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		client, err := a.Authenticate(r)
+		if err != nil {
+			w.Header().Set("content-type", "application/json")
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			w.WriteHeader(http.StatusUnauthorized)
+			_ = json.NewEncoder(w).Encode(&entity.ErrorResponse{Error: err.Error()})
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(WithClientID(r.Context(), client)))
+	})
+}
@@ -0,0 +1,189 @@
+package auth_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Mi7teR/aggregator/internal/auth"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestParseKeys(t *testing.T) {
+	tests := []struct {
+		name    string
+		list    string
+		want    map[string]string
+		wantErr error
+	}{
+		{"empty", "", map[string]string{}, nil},
+		{"comma separated", "alice:k1, bob:k2", map[string]string{"k1": "alice", "k2": "bob"}, nil},
+		{"file lines", "# clients\nalice:k1\n\nbob:k2\n", map[string]string{"k1": "alice", "k2": "bob"}, nil},
+		{"missing separator", "alice", nil, auth.ErrInvalidKey},
+		{"empty key", "alice:", nil, auth.ErrInvalidKey},
+		{"duplicate key", "alice:k1,bob:k1", nil, auth.ErrDuplicateKey},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := auth.ParseKeys(tt.list)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ParseKeys() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseKeys() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAuthenticator_Middleware(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+
+	keys, err := auth.LoadJWKS(writeJWKS(t, &key.PublicKey))
+	if err != nil {
+		t.Fatalf("LoadJWKS() error = %v", err)
+	}
+
+	a := auth.New(
+		auth.WithKeys(map[string]string{"static-key": "alice"}),
+		auth.WithJWT(auth.NewJWTVerifier(keys, "issuer", "")),
+	)
+
+	h := a.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(auth.ClientID(r.Context())))
+	}))
+
+	sign := func(claims jwt.RegisteredClaims) string {
+		token := jwt.NewWithClaims(jwt.SigningMethodES256, claims)
+		token.Header["kid"] = "test"
+		signed, errSign := token.SignedString(key)
+		if errSign != nil {
+			t.Fatalf("sign token: %v", errSign)
+		}
+		return signed
+	}
+
+	expiresAt := jwt.NewNumericDate(time.Now().Add(time.Hour))
+
+	tests := []struct {
+		name       string
+		header     string
+		value      string
+		wantStatus int
+		wantClient string
+	}{
+		{"no credentials", "", "", http.StatusUnauthorized, ""},
+		{"api key header", "X-API-Key", "static-key", http.StatusOK, "key:alice"},
+		{"bearer api key", "Authorization", "Bearer static-key", http.StatusOK, "key:alice"},
+		{"unknown api key", "X-API-Key", "other", http.StatusUnauthorized, ""},
+		{
+			"valid jwt",
+			"Authorization",
+			"Bearer " + sign(jwt.RegisteredClaims{Subject: "bob", Issuer: "issuer", ExpiresAt: expiresAt}),
+			http.StatusOK,
+			"jwt:bob",
+		},
+		{
+			"jwt subject named like an api key client",
+			"Authorization",
+			"Bearer " + sign(jwt.RegisteredClaims{Subject: "alice", Issuer: "issuer", ExpiresAt: expiresAt}),
+			http.StatusOK,
+			"jwt:alice",
+		},
+		{
+			"jwt with wrong issuer",
+			"Authorization",
+			"Bearer " + sign(jwt.RegisteredClaims{Subject: "bob", Issuer: "other", ExpiresAt: expiresAt}),
+			http.StatusUnauthorized,
+			"",
+		},
+		{
+			"expired jwt",
+			"Authorization",
+			"Bearer " + sign(jwt.RegisteredClaims{
+				Subject:   "bob",
+				Issuer:    "issuer",
+				ExpiresAt: jwt.NewNumericDate(time.Now().Add(-time.Hour)),
+			}),
+			http.StatusUnauthorized,
+			"",
+		},
+		{
+			"jwt without subject",
+			"Authorization",
+			"Bearer " + sign(jwt.RegisteredClaims{Issuer: "issuer", ExpiresAt: expiresAt}),
+			http.StatusUnauthorized,
+			"",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/tasks", nil)
+			if tt.header != "" {
+				req.Header.Set(tt.header, tt.value)
+			}
+
+			rr := httptest.NewRecorder()
+			h.ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rr.Code, tt.wantStatus)
+			}
+			if tt.wantStatus == http.StatusOK && rr.Body.String() != tt.wantClient {
+				t.Errorf("client = %q, want %q", rr.Body.String(), tt.wantClient)
+			}
+		})
+	}
+}
+
+func TestLoadJWKS_NoKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(path, []byte(`{"keys":[{"kty":"oct","k":"c2VjcmV0"}]}`), 0o600); err != nil {
+		t.Fatalf("write jwks: %v", err)
+	}
+
+	if _, err := auth.LoadJWKS(path); !errors.Is(err, auth.ErrNoKeys) {
+		t.Errorf("LoadJWKS() error = %v, want %v", err, auth.ErrNoKeys)
+	}
+}
+
+func writeJWKS(t *testing.T, key *ecdsa.PublicKey) string {
+	t.Helper()
+
+	enc := base64.RawURLEncoding
+	set := map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "EC",
+			"kid": "test",
+			"use": "sig",
+			"crv": "P-256",
+			"x":   enc.EncodeToString(key.X.FillBytes(make([]byte, 32))),
+			"y":   enc.EncodeToString(key.Y.FillBytes(make([]byte, 32))),
+		}},
+	}
+
+	data, err := json.Marshal(set)
+	if err != nil {
+		t.Fatalf("marshal jwks: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err = os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatalf("write jwks: %v", err)
+	}
+
+	return path
+}
@@ -0,0 +1,215 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	ErrNoKeys          = errors.New("jwks contains no usable signing keys")
+	ErrInvalidJWK      = errors.New("invalid jwk")
+	ErrUnknownKey      = errors.New("unknown signing key")
+	ErrMissingSubject  = errors.New("token has no subject")
+	errUnsupportedKind = errors.New("unsupported key type")
+	errUnsupportedCrv  = errors.New("unsupported curve")
+	errKeyParameter    = errors.New("invalid key parameter")
+)
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+type jwks struct {
+	Keys []jwk `json:"keys"`
+}
+
+// LoadJWKS reads the public signing keys of a JSON Web Key Set file, keyed
+// by their kid. RSA, EC and Ed25519 keys are supported, others are skipped.
+func LoadJWKS(path string) (map[string]crypto.PublicKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read jwks file: %w", err)
+	}
+
+	var set jwks
+	if err = json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("unmarshal jwks: %w", err)
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for i := range set.Keys {
+		k := &set.Keys[i]
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+
+		key, errKey := k.publicKey()
+		if errors.Is(errKey, errUnsupportedKind) {
+			continue
+		}
+		if errKey != nil {
+			return nil, fmt.Errorf("%w %q: %w", ErrInvalidJWK, k.Kid, errKey)
+		}
+
+		keys[k.Kid] = key
+	}
+
+	if len(keys) == 0 {
+		return nil, ErrNoKeys
+	}
+
+	return keys, nil
+}
+
+func (k *jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+
+		if !e.IsInt64() {
+			return nil, fmt.Errorf("%w: exponent too large", errKeyParameter)
+		}
+
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("%w %q", errUnsupportedCrv, k.Crv)
+		}
+
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, fmt.Errorf("%w %q", errUnsupportedCrv, k.Crv)
+		}
+
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("%w: ed25519 key size", errKeyParameter)
+		}
+
+		return ed25519.PublicKey(x), nil
+	default:
+		return nil, errUnsupportedKind
+	}
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(b) == 0 {
+		return nil, errKeyParameter
+	}
+
+	return new(big.Int).SetBytes(b), nil
+}
+
+// JWTVerifier validates signed bearer tokens against a fixed set of public
+// keys. Tokens must carry an expiry and a subject, which becomes the client
+// id.
+type JWTVerifier struct {
+	keys   map[string]crypto.PublicKey
+	parser *jwt.Parser
+}
+
+// NewJWTVerifier returns a verifier for the keys. Issuer and audience are
+// only checked when not empty.
+func NewJWTVerifier(keys map[string]crypto.PublicKey, issuer, audience string) *JWTVerifier {
+	validMethods := []string{
+		"RS256", "RS384", "RS512",
+		"PS256", "PS384", "PS512",
+		"ES256", "ES384", "ES512",
+		"EdDSA",
+	}
+
+	opts := []jwt.ParserOption{jwt.WithValidMethods(validMethods), jwt.WithExpirationRequired()}
+	if issuer != "" {
+		opts = append(opts, jwt.WithIssuer(issuer))
+	}
+	if audience != "" {
+		opts = append(opts, jwt.WithAudience(audience))
+	}
+
+	return &JWTVerifier{keys: keys, parser: jwt.NewParser(opts...)}
+}
+
+func (v *JWTVerifier) Verify(token string) (string, error) {
+	var claims jwt.RegisteredClaims
+	if _, err := v.parser.ParseWithClaims(token, &claims, v.key); err != nil {
+		return "", err
+	}
+
+	if claims.Subject == "" {
+		return "", ErrMissingSubject
+	}
+
+	return claims.Subject, nil
+}
+
+// key picks the verification key by the token kid. Tokens without a kid are
+// accepted when the set holds a single key.
+func (v *JWTVerifier) key(t *jwt.Token) (interface{}, error) {
+	kid, _ := t.Header["kid"].(string)
+	if kid == "" && len(v.keys) == 1 {
+		for _, key := range v.keys {
+			return key, nil
+		}
+	}
+
+	key, ok := v.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownKey, kid)
+	}
+
+	return key, nil
+}
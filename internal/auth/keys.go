@@ -0,0 +1,55 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+var (
+	ErrInvalidKey   = errors.New("invalid api key entry")
+	ErrDuplicateKey = errors.New("duplicate api key")
+)
+
+// ParseKeys parses "client:key" entries separated by commas or new lines
+// into a map of keys to client ids. Blank entries and lines starting with #
+// are skipped.
+func ParseKeys(list string) (map[string]string, error) {
+	keys := make(map[string]string)
+
+	entries := strings.FieldsFunc(list, func(r rune) bool {
+		return r == ',' || r == '\n'
+	})
+
+	for i, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" || strings.HasPrefix(entry, "#") {
+			continue
+		}
+
+		client, key, ok := strings.Cut(entry, ":")
+		client, key = strings.TrimSpace(client), strings.TrimSpace(key)
+		if !ok || client == "" || key == "" {
+			return nil, fmt.Errorf("%w at position %d", ErrInvalidKey, i+1)
+		}
+
+		if _, ok = keys[key]; ok {
+			return nil, fmt.Errorf("%w for client %q", ErrDuplicateKey, client)
+		}
+
+		keys[key] = client
+	}
+
+	return keys, nil
+}
+
+// LoadKeysFile reads api keys in the ParseKeys format from a file.
+func LoadKeysFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read api keys file: %w", err)
+	}
+
+	return ParseKeys(string(data))
+}
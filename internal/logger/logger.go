@@ -0,0 +1,97 @@
+package logger
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+const (
+	FormatJSON = "json"
+	FormatText = "text"
+
+	KeyTaskID    = "task_id"
+	KeyRequestID = "request_id"
+)
+
+var ErrUnknownFormat = errors.New("unknown log format")
+
+type taskIDKey struct{}
+
+// New builds a leveled logger that adds the task and request ids found in
+// the context to every record.
+func New(w io.Writer, level, format string) (*slog.Logger, error) {
+	var lvl slog.Level
+	if level != "" {
+		if err := lvl.UnmarshalText([]byte(level)); err != nil {
+			return nil, err
+		}
+	}
+
+	opts := &slog.HandlerOptions{Level: lvl}
+
+	var h slog.Handler
+	switch strings.ToLower(format) {
+	case "", FormatJSON:
+		h = slog.NewJSONHandler(w, opts)
+	case FormatText:
+		h = slog.NewTextHandler(w, opts)
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownFormat, format)
+	}
+
+	return slog.New(&contextHandler{Handler: h}), nil
+}
+
+func WithTaskID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, taskIDKey{}, id)
+}
+
+func TaskID(ctx context.Context) string {
+	id, _ := ctx.Value(taskIDKey{}).(string)
+	return id
+}
+
+// WithRequestID stores the id under the key used by chi's RequestID
+// middleware, so it can be carried over to contexts detached from the
+// request.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	if id == "" {
+		return ctx
+	}
+
+	return context.WithValue(ctx, middleware.RequestIDKey, id)
+}
+
+func RequestID(ctx context.Context) string {
+	return middleware.GetReqID(ctx)
+}
+
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h *contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id := TaskID(ctx); id != "" {
+		r.AddAttrs(slog.String(KeyTaskID, id))
+	}
+
+	if id := RequestID(ctx); id != "" {
+		r.AddAttrs(slog.String(KeyRequestID, id))
+	}
+
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h *contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+func (h *contextHandler) WithGroup(name string) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithGroup(name)}
+}
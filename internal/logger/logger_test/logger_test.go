@@ -0,0 +1,107 @@
+package logger_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Mi7teR/aggregator/internal/logger"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name    string
+		level   string
+		format  string
+		wantErr bool
+	}{
+		{"defaults", "", "", false},
+		{"debug text", "debug", "text", false},
+		{"warn json", "WARN", "JSON", false},
+		{"unknown level", "verbose", "json", true},
+		{"unknown format", "info", "xml", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := logger.New(&bytes.Buffer{}, tt.level, tt.format)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("New() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	if _, err := logger.New(&bytes.Buffer{}, "", "xml"); !errors.Is(err, logger.ErrUnknownFormat) {
+		t.Errorf("New() error = %v, want %v", err, logger.ErrUnknownFormat)
+	}
+}
+
+func TestContextAttrs(t *testing.T) {
+	var buf bytes.Buffer
+
+	l, err := logger.New(&buf, "debug", "json")
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	ctx := logger.WithRequestID(logger.WithTaskID(context.Background(), "task-1"), "req-1")
+	l.With("component", "test").DebugContext(ctx, "hello")
+
+	var record map[string]any
+	if err = json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("expected json log line, got %q", buf.String())
+	}
+
+	for key, want := range map[string]string{
+		logger.KeyTaskID:    "task-1",
+		logger.KeyRequestID: "req-1",
+		"component":         "test",
+		"msg":               "hello",
+	} {
+		if record[key] != want {
+			t.Errorf("log attr %s = %v, want %v", key, record[key], want)
+		}
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	var buf bytes.Buffer
+
+	l, err := logger.New(&buf, "info", "json")
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	prev := slog.Default()
+	slog.SetDefault(l)
+	defer slog.SetDefault(prev)
+
+	r := chi.NewRouter()
+	r.Use(middleware.RequestID, logger.Middleware)
+	r.Get("/task/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/task/task-1", nil))
+
+	var record map[string]any
+	if err = json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("expected json log line, got %q", buf.String())
+	}
+
+	if record[logger.KeyTaskID] != "task-1" {
+		t.Errorf("log attr %s = %v, want task-1", logger.KeyTaskID, record[logger.KeyTaskID])
+	}
+	if id, _ := record[logger.KeyRequestID].(string); id == "" {
+		t.Errorf("expected %s in request log, got %v", logger.KeyRequestID, record)
+	}
+	if record["status"] != float64(http.StatusTeapot) {
+		t.Errorf("log attr status = %v, want %d", record["status"], http.StatusTeapot)
+	}
+}
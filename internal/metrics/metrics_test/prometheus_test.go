@@ -0,0 +1,68 @@
+package metrics_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Mi7teR/aggregator/internal/metrics"
+	"github.com/Mi7teR/aggregator/internal/task/delivery/api"
+	"github.com/Mi7teR/aggregator/internal/task/entity"
+	"github.com/Mi7teR/aggregator/internal/task/repository"
+	"github.com/Mi7teR/aggregator/internal/task/service"
+	"github.com/google/uuid"
+)
+
+type stats struct{}
+
+func (stats) QueueDepth() int { return 3 }
+
+func (stats) InFlight() int { return 2 }
+
+func TestPrometheus(t *testing.T) {
+	m := metrics.NewPrometheus()
+	m.WatchService(stats{})
+
+	s := service.NewService(repository.NewTaskInMemoryRepository(), time.Second*30, service.WithMetrics(m))
+	r := api.NewRouter(api.NewHandler(s), api.WithMiddleware(m.Middleware), api.WithMetricsHandler(m.Handler()))
+
+	m.TaskCreated(entity.MethodGet)
+	m.TaskFinished(entity.TaskStatusDone)
+	m.UpstreamRequest(entity.MethodGet, http.StatusOK, time.Millisecond*20)
+	m.UpstreamRequest(entity.MethodPost, 0, time.Millisecond*20)
+
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/task/"+uuid.New().String(), nil))
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected response code %d. Got %d", http.StatusOK, rr.Code)
+	}
+
+	body, err := io.ReadAll(rr.Body)
+	if err != nil {
+		t.Fatalf("expected to read metrics, got %v", err)
+	}
+
+	for _, want := range []string{
+		`aggregator_tasks_created_total{method="GET"} 1`,
+		`aggregator_tasks_completed_total{status="done"} 1`,
+		`aggregator_upstream_request_duration_seconds_count{code="200",method="GET"} 1`,
+		`aggregator_upstream_request_duration_seconds_count{code="error",method="POST"} 1`,
+		`aggregator_queue_depth 3`,
+		`aggregator_inflight_executions 2`,
+		`aggregator_http_requests_total{code="404",method="GET",route="/task/{id}"} 1`,
+	} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("expected metrics to contain %q", want)
+		}
+	}
+
+	if err = s.Shutdown(context.Background()); err != nil {
+		t.Errorf("Shutdown() error = %v", err)
+	}
+}
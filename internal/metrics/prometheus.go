@@ -0,0 +1,145 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Mi7teR/aggregator/internal/task/entity"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const (
+	namespace     = "aggregator"
+	upstreamError = "error"
+	unknownRoute  = "unmatched"
+)
+
+type Stats interface {
+	QueueDepth() int
+	InFlight() int
+}
+
+type Prometheus struct {
+	registry *prometheus.Registry
+
+	tasksCreated  *prometheus.CounterVec
+	tasksFinished *prometheus.CounterVec
+	upstream      *prometheus.HistogramVec
+	httpRequests  *prometheus.CounterVec
+	httpDurations *prometheus.HistogramVec
+}
+
+func NewPrometheus() *Prometheus {
+	p := &Prometheus{
+		registry: prometheus.NewRegistry(),
+		tasksCreated: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "tasks_created_total",
+			Help:      "Number of tasks accepted for execution.",
+		}, []string{"method"}),
+		tasksFinished: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "tasks_completed_total",
+			Help:      "Number of tasks that reached a terminal status.",
+		}, []string{"status"}),
+		upstream: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "upstream_request_duration_seconds",
+			Help:      "Time until upstream response headers arrived, per attempt.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "code"}),
+		httpRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "Number of API requests served.",
+		}, []string{"method", "route", "code"}),
+		httpDurations: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "API request latency.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "route"}),
+	}
+
+	p.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		p.tasksCreated,
+		p.tasksFinished,
+		p.upstream,
+		p.httpRequests,
+		p.httpDurations,
+	)
+
+	return p
+}
+
+// WatchService exports the queue depth and in-flight executions of s.
+func (p *Prometheus) WatchService(s Stats) {
+	p.registry.MustRegister(
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "queue_depth",
+			Help:      "Number of tasks waiting for a worker.",
+		}, func() float64 {
+			return float64(s.QueueDepth())
+		}),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "inflight_executions",
+			Help:      "Number of tasks currently being executed.",
+		}, func() float64 {
+			return float64(s.InFlight())
+		}),
+	)
+}
+
+func (p *Prometheus) TaskCreated(method entity.TaskMethod) {
+	p.tasksCreated.WithLabelValues(method.String()).Inc()
+}
+
+func (p *Prometheus) TaskFinished(status entity.TaskResultStatus) {
+	p.tasksFinished.WithLabelValues(status.String()).Inc()
+}
+
+func (p *Prometheus) UpstreamRequest(method entity.TaskMethod, statusCode int, d time.Duration) {
+	code := upstreamError
+	if statusCode > 0 {
+		code = strconv.Itoa(statusCode)
+	}
+
+	p.upstream.WithLabelValues(method.String(), code).Observe(d.Seconds())
+}
+
+// Middleware records API request metrics labelled by the matched chi route
+// pattern, so task ids do not end up in label values.
+func (p *Prometheus) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		start := time.Now()
+
+		next.ServeHTTP(ww, r)
+
+		route := unknownRoute
+		if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+			route = rctx.RoutePattern()
+		}
+
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+
+		p.httpRequests.WithLabelValues(r.Method, route, strconv.Itoa(status)).Inc()
+		p.httpDurations.WithLabelValues(r.Method, route).Observe(time.Since(start).Seconds())
+	})
+}
+
+func (p *Prometheus) Handler() http.Handler {
+	return promhttp.HandlerFor(p.registry, promhttp.HandlerOpts{Registry: p.registry})
+}
@@ -0,0 +1,66 @@
+package policy
+
+import (
+	"fmt"
+	"net/netip"
+	"strconv"
+	"strings"
+)
+
+const maxPort = 65535
+
+// SplitList splits a comma separated list, dropping empty items.
+func SplitList(s string) []string {
+	var values []string
+
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+
+	return values
+}
+
+// ParseNets parses CIDR prefixes. Plain addresses are treated as single
+// host prefixes.
+func ParseNets(values []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(values))
+
+	for _, v := range values {
+		if !strings.Contains(v, "/") {
+			addr, err := netip.ParseAddr(v)
+			if err != nil {
+				return nil, fmt.Errorf("parse address %q: %w", v, err)
+			}
+
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+
+			continue
+		}
+
+		prefix, err := netip.ParsePrefix(v)
+		if err != nil {
+			return nil, fmt.Errorf("parse cidr %q: %w", v, err)
+		}
+
+		prefixes = append(prefixes, prefix.Masked())
+	}
+
+	return prefixes, nil
+}
+
+func ParsePorts(values []string) ([]int, error) {
+	ports := make([]int, 0, len(values))
+
+	for _, v := range values {
+		port, err := strconv.Atoi(v)
+		if err != nil || port < 0 || port > maxPort {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidPort, v)
+		}
+
+		ports = append(ports, port)
+	}
+
+	return ports, nil
+}
@@ -0,0 +1,235 @@
+package policy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+var (
+	ErrDestinationNotAllowed = errors.New("destination not allowed")
+	ErrSchemeNotAllowed      = errors.New("scheme not allowed")
+	ErrHostNotAllowed        = errors.New("host not allowed")
+	ErrAddressNotAllowed     = errors.New("address not allowed")
+	ErrPortBlocked           = errors.New("port blocked")
+	ErrInvalidPort           = errors.New("invalid port")
+)
+
+// Policy restricts the destinations tasks may reach. Deny rules win over
+// allow rules, and empty allow lists allow everything.
+type Policy struct {
+	AllowedSchemes []string
+	AllowedHosts   []string
+	DeniedHosts    []string
+	AllowedNets    []netip.Prefix
+	DeniedNets     []netip.Prefix
+	BlockedPorts   []int
+}
+
+// PrivateNets lists loopback, link-local, private and other special purpose
+// ranges that usually must not be reachable from task URLs.
+func PrivateNets() []netip.Prefix {
+	return []netip.Prefix{
+		netip.MustParsePrefix("0.0.0.0/8"),
+		netip.MustParsePrefix("10.0.0.0/8"),
+		netip.MustParsePrefix("100.64.0.0/10"),
+		netip.MustParsePrefix("127.0.0.0/8"),
+		netip.MustParsePrefix("169.254.0.0/16"),
+		netip.MustParsePrefix("172.16.0.0/12"),
+		netip.MustParsePrefix("192.0.0.0/24"),
+		netip.MustParsePrefix("192.168.0.0/16"),
+		netip.MustParsePrefix("198.18.0.0/15"),
+		netip.MustParsePrefix("224.0.0.0/4"),
+		netip.MustParsePrefix("240.0.0.0/4"),
+		netip.MustParsePrefix("::/128"),
+		netip.MustParsePrefix("::1/128"),
+		netip.MustParsePrefix("fc00::/7"),
+		netip.MustParsePrefix("fe80::/10"),
+		netip.MustParsePrefix("ff00::/8"),
+	}
+}
+
+// CheckURL validates the parts of a URL known before dialing: scheme, host
+// name, port and literal IP addresses.
+func (p *Policy) CheckURL(u *url.URL) error {
+	if len(p.AllowedSchemes) > 0 && !containsFold(p.AllowedSchemes, u.Scheme) {
+		return deny(ErrSchemeNotAllowed, u.Scheme)
+	}
+
+	host := u.Hostname()
+	if err := p.checkHost(host); err != nil {
+		return err
+	}
+
+	port := u.Port()
+	if port == "" {
+		port = defaultPort(u.Scheme)
+	}
+
+	if err := p.checkPort(port); err != nil {
+		return err
+	}
+
+	if addr, err := netip.ParseAddr(host); err == nil {
+		return p.checkAddr(addr)
+	}
+
+	return nil
+}
+
+// CheckAddress validates a resolved "ip:port" address right before a
+// connection is made.
+func (p *Policy) CheckAddress(address string) error {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return deny(ErrAddressNotAllowed, address)
+	}
+
+	if err = p.checkPort(port); err != nil {
+		return err
+	}
+
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return deny(ErrAddressNotAllowed, host)
+	}
+
+	return p.checkAddr(addr)
+}
+
+// CheckResolved validates the URL and every address its host name resolves
+// to. It is meant for requests sent through a proxy, where the dialed
+// address is the proxy and the proxy resolves the destination again, so it
+// narrows but does not close the DNS rebinding window.
+func (p *Policy) CheckResolved(ctx context.Context, u *url.URL) error {
+	if err := p.CheckURL(u); err != nil {
+		return err
+	}
+
+	host := u.Hostname()
+	if _, err := netip.ParseAddr(host); err == nil {
+		return nil
+	}
+
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		return err
+	}
+
+	for _, addr := range addrs {
+		if err = p.checkAddr(addr); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Control can be used as net.Dialer.Control, so the policy is applied to the
+// address actually dialed and DNS rebinding cannot bypass it.
+func (p *Policy) Control(_, address string, _ syscall.RawConn) error {
+	return p.CheckAddress(address)
+}
+
+func (p *Policy) checkHost(host string) error {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+
+	for _, pattern := range p.DeniedHosts {
+		if matchHost(pattern, host) {
+			return deny(ErrHostNotAllowed, host)
+		}
+	}
+
+	if len(p.AllowedHosts) == 0 {
+		return nil
+	}
+
+	for _, pattern := range p.AllowedHosts {
+		if matchHost(pattern, host) {
+			return nil
+		}
+	}
+
+	return deny(ErrHostNotAllowed, host)
+}
+
+func (p *Policy) checkPort(port string) error {
+	n, err := strconv.Atoi(port)
+	if err != nil {
+		return deny(ErrInvalidPort, port)
+	}
+
+	for _, blocked := range p.BlockedPorts {
+		if n == blocked {
+			return deny(ErrPortBlocked, port)
+		}
+	}
+
+	return nil
+}
+
+func (p *Policy) checkAddr(addr netip.Addr) error {
+	addr = addr.Unmap()
+
+	for _, prefix := range p.DeniedNets {
+		if prefix.Contains(addr) {
+			return deny(ErrAddressNotAllowed, addr.String())
+		}
+	}
+
+	if len(p.AllowedNets) == 0 {
+		return nil
+	}
+
+	for _, prefix := range p.AllowedNets {
+		if prefix.Contains(addr) {
+			return nil
+		}
+	}
+
+	return deny(ErrAddressNotAllowed, addr.String())
+}
+
+// matchHost matches a host against an exact name or, for patterns starting
+// with "*." or ".", against the domain and all of its subdomains.
+func matchHost(pattern, host string) bool {
+	pattern = strings.ToLower(strings.TrimSuffix(pattern, "."))
+
+	domain, ok := strings.CutPrefix(pattern, "*")
+	if !ok && !strings.HasPrefix(pattern, ".") {
+		return host == pattern
+	}
+
+	domain = strings.TrimPrefix(domain, ".")
+
+	return host == domain || strings.HasSuffix(host, "."+domain)
+}
+
+func deny(reason error, value string) error {
+	return fmt.Errorf("%w: %w: %q", ErrDestinationNotAllowed, reason, value)
+}
+
+func defaultPort(scheme string) string {
+	switch strings.ToLower(scheme) {
+	case "https":
+		return "443"
+	default:
+		return "80"
+	}
+}
+
+func containsFold(values []string, v string) bool {
+	for _, value := range values {
+		if strings.EqualFold(value, v) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,126 @@
+package policy_test
+
+import (
+	"errors"
+	"net/netip"
+	"net/url"
+	"testing"
+
+	"github.com/Mi7teR/aggregator/internal/policy"
+)
+
+func TestPolicy_CheckURL(t *testing.T) {
+	p := &policy.Policy{
+		AllowedSchemes: []string{"http", "https"},
+		DeniedHosts:    []string{"metadata.internal", "*.corp.example"},
+		DeniedNets:     policy.PrivateNets(),
+		BlockedPorts:   []int{25},
+	}
+
+	tests := []struct {
+		name    string
+		url     string
+		wantErr error
+	}{
+		{"public host", "https://example.com/path", nil},
+		{"public ip", "http://93.184.216.34", nil},
+		{"scheme not allowed", "ftp://example.com", policy.ErrSchemeNotAllowed},
+		{"denied host", "http://metadata.internal", policy.ErrHostNotAllowed},
+		{"denied subdomain", "http://api.corp.example", policy.ErrHostNotAllowed},
+		{"denied domain itself", "http://corp.example", policy.ErrHostNotAllowed},
+		{"blocked port", "http://example.com:25", policy.ErrPortBlocked},
+		{"loopback", "http://127.0.0.1:8080", policy.ErrAddressNotAllowed},
+		{"metadata ip", "http://169.254.169.254/latest", policy.ErrAddressNotAllowed},
+		{"private range", "http://10.1.2.3", policy.ErrAddressNotAllowed},
+		{"ipv6 loopback", "http://[::1]:80", policy.ErrAddressNotAllowed},
+		{"ipv4 mapped ipv6", "http://[::ffff:127.0.0.1]", policy.ErrAddressNotAllowed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, err := url.Parse(tt.url)
+			if err != nil {
+				t.Fatalf("url.Parse() error = %v", err)
+			}
+
+			err = p.CheckURL(u)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("CheckURL() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil && !errors.Is(err, policy.ErrDestinationNotAllowed) {
+				t.Errorf("CheckURL() error = %v, want %v", err, policy.ErrDestinationNotAllowed)
+			}
+		})
+	}
+}
+
+func TestPolicy_Allowlists(t *testing.T) {
+	p := &policy.Policy{
+		AllowedHosts: []string{"api.example.com", ".partner.example"},
+		AllowedNets:  []netip.Prefix{netip.MustParsePrefix("203.0.113.0/24")},
+	}
+
+	tests := []struct {
+		name    string
+		url     string
+		wantErr error
+	}{
+		{"allowed host", "https://api.example.com", nil},
+		{"allowed subdomain", "https://eu.partner.example", nil},
+		{"host not in allowlist", "https://example.com", policy.ErrHostNotAllowed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, err := url.Parse(tt.url)
+			if err != nil {
+				t.Fatalf("url.Parse() error = %v", err)
+			}
+
+			if err = p.CheckURL(u); !errors.Is(err, tt.wantErr) {
+				t.Errorf("CheckURL() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	addresses := []struct {
+		address string
+		wantErr error
+	}{
+		{"203.0.113.10:443", nil},
+		{"198.51.100.1:443", policy.ErrAddressNotAllowed},
+		{"not-an-address", policy.ErrAddressNotAllowed},
+	}
+	for _, tt := range addresses {
+		if err := p.CheckAddress(tt.address); !errors.Is(err, tt.wantErr) {
+			t.Errorf("CheckAddress(%q) error = %v, want %v", tt.address, err, tt.wantErr)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	nets, err := policy.ParseNets(policy.SplitList(" 10.0.0.0/8, 192.168.1.7 ,, fd00::/8"))
+	if err != nil {
+		t.Fatalf("ParseNets() error = %v", err)
+	}
+
+	want := []netip.Prefix{
+		netip.MustParsePrefix("10.0.0.0/8"),
+		netip.MustParsePrefix("192.168.1.7/32"),
+		netip.MustParsePrefix("fd00::/8"),
+	}
+	if len(nets) != len(want) {
+		t.Fatalf("ParseNets() = %v, want %v", nets, want)
+	}
+	for i := range want {
+		if nets[i] != want[i] {
+			t.Errorf("ParseNets()[%d] = %v, want %v", i, nets[i], want[i])
+		}
+	}
+
+	if _, err = policy.ParseNets([]string{"10.0.0.0/33"}); err == nil {
+		t.Errorf("ParseNets() expected error for invalid cidr")
+	}
+
+	if _, err = policy.ParsePorts([]string{"22", "70000"}); !errors.Is(err, policy.ErrInvalidPort) {
+		t.Errorf("ParsePorts() error = %v, want %v", err, policy.ErrInvalidPort)
+	}
+}
@@ -0,0 +1,110 @@
+package ratelimit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Mi7teR/aggregator/internal/auth"
+	"github.com/Mi7teR/aggregator/internal/task/entity"
+)
+
+var (
+	ErrRateLimited       = errors.New("rate limit exceeded")
+	ErrBatchExceedsBurst = errors.New("batch exceeds the rate limit burst")
+)
+
+type ctxKey struct{}
+
+// Middleware limits requests per authenticated client, falling back to the
+// client ip when authentication is disabled. Every response carries the
+// X-RateLimit-* headers; rejected requests get 429 with Retry-After.
+func (l *Limiter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !respond(w, l.Allow(requestKey(r))) {
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// BatchMiddleware is Middleware for requests that submit several tasks at
+// once. It takes no token itself: the handler charges the whole batch with
+// Charge once it knows how many tasks it holds.
+func (l *Limiter) BatchMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), ctxKey{}, l)))
+	})
+}
+
+// Burst returns the most tokens a single request can take.
+func (l *Limiter) Burst() int {
+	return l.burst
+}
+
+// Charge takes a token per task of a batch that passed BatchMiddleware, so
+// a batch costs as much as sending its tasks one by one. It responds with
+// 429 and Retry-After when the tokens are not available yet, and with 413
+// when the batch is larger than the burst and could never be allowed.
+// Requests that passed no limiter are not charged.
+func Charge(w http.ResponseWriter, r *http.Request, n int) bool {
+	l, ok := r.Context().Value(ctxKey{}).(*Limiter)
+	if !ok {
+		return true
+	}
+
+	if n > l.burst {
+		w.Header().Set("content-type", "application/json")
+		w.WriteHeader(http.StatusRequestEntityTooLarge)
+		_ = json.NewEncoder(w).Encode(&entity.ErrorResponse{
+			Error: fmt.Sprintf("%s of %d", ErrBatchExceedsBurst, l.burst),
+		})
+		return false
+	}
+
+	return respond(w, l.AllowN(requestKey(r), max(n, 1)))
+}
+
+// respond sets the rate limit headers and, when the request was not
+// allowed, writes the 429 response.
+func respond(w http.ResponseWriter, res Result) bool {
+	w.Header().Set("X-RateLimit-Limit", strconv.Itoa(res.Limit))
+	w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(res.Remaining))
+	w.Header().Set("X-RateLimit-Reset", strconv.Itoa(seconds(res.Reset)))
+
+	if res.Allowed {
+		return true
+	}
+
+	w.Header().Set("content-type", "application/json")
+	w.Header().Set("retry-after", strconv.Itoa(seconds(res.RetryAfter)))
+	w.WriteHeader(http.StatusTooManyRequests)
+	_ = json.NewEncoder(w).Encode(&entity.ErrorResponse{Error: ErrRateLimited.Error()})
+
+	return false
+}
+
+func requestKey(r *http.Request) string {
+	if client := auth.ClientID(r.Context()); client != "" {
+		return "client:" + client
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	return "ip:" + host
+}
+
+// seconds rounds d up to whole seconds as used by the rate limit headers.
+func seconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,171 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+const sweepInterval = time.Minute
+
+// Result describes the state of a bucket after a request was counted.
+type Result struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	RetryAfter time.Duration
+	Reset      time.Duration
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Limiter is a keyed token bucket limiter. Every key gets a bucket of burst
+// tokens refilled at rate tokens per second. Buckets that have refilled
+// completely are dropped, so idle keys do not pile up.
+type Limiter struct {
+	rate  float64
+	burst int
+	now   func() time.Time
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+type Option func(l *Limiter)
+
+// WithClock replaces the time source of the limiter.
+func WithClock(now func() time.Time) Option {
+	return func(l *Limiter) {
+		l.now = now
+	}
+}
+
+func New(rate float64, burst int, opts ...Option) *Limiter {
+	l := &Limiter{
+		rate:    rate,
+		burst:   burst,
+		now:     time.Now,
+		buckets: make(map[string]*bucket),
+	}
+	for _, opt := range opts {
+		opt(l)
+	}
+
+	return l
+}
+
+// Allow takes a token from the bucket of key if one is available.
+func (l *Limiter) Allow(key string) Result {
+	return l.AllowN(key, 1)
+}
+
+// AllowN takes n tokens from the bucket of key if they are all available.
+// Nothing is taken otherwise.
+func (l *Limiter) AllowN(key string, n int) Result {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b := l.bucket(key)
+
+	res := Result{Limit: l.burst}
+	if b.tokens >= float64(n) {
+		b.tokens -= float64(n)
+		res.Allowed = true
+	} else {
+		res.RetryAfter = l.duration(float64(n) - b.tokens)
+	}
+
+	res.Remaining = int(math.Floor(b.tokens))
+	res.Reset = l.duration(float64(l.burst) - b.tokens)
+
+	return res
+}
+
+// Delay reports how long it takes until a token of key is available,
+// without taking it.
+func (l *Limiter) Delay(key string) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.duration(1 - l.bucket(key).tokens)
+}
+
+// Wait takes a token from the bucket of key, blocking until one is
+// available or ctx ends. Waiting callers reserve their token up front, so
+// they are served in the order they arrived.
+func (l *Limiter) Wait(ctx context.Context, key string) error {
+	l.mu.Lock()
+	b := l.bucket(key)
+	b.tokens--
+	wait := l.duration(-b.tokens)
+	l.mu.Unlock()
+
+	if wait <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		l.mu.Lock()
+		b.tokens++
+		l.mu.Unlock()
+
+		return ctx.Err()
+	}
+}
+
+// bucket returns the refilled bucket of key. The caller must hold mu.
+func (l *Limiter) bucket(key string) *bucket {
+	now := l.now()
+	l.sweep(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(l.burst), last: now}
+		l.buckets[key] = b
+	}
+	l.refill(b, now)
+
+	return b
+}
+
+func (l *Limiter) refill(b *bucket, now time.Time) {
+	elapsed := now.Sub(b.last).Seconds()
+	if elapsed > 0 {
+		b.tokens = math.Min(float64(l.burst), b.tokens+elapsed*l.rate)
+		b.last = now
+	}
+}
+
+// duration returns how long it takes to refill the given number of tokens.
+func (l *Limiter) duration(tokens float64) time.Duration {
+	if tokens <= 0 {
+		return 0
+	}
+
+	return time.Duration(tokens / l.rate * float64(time.Second))
+}
+
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < sweepInterval {
+		return
+	}
+	l.lastSweep = now
+
+	for key, b := range l.buckets {
+		l.refill(b, now)
+		if b.tokens >= float64(l.burst) {
+			delete(l.buckets, key)
+		}
+	}
+}
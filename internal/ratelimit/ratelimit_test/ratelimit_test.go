@@ -0,0 +1,148 @@
+package ratelimit_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Mi7teR/aggregator/internal/auth"
+	"github.com/Mi7teR/aggregator/internal/ratelimit"
+)
+
+func TestLimiter_Allow(t *testing.T) {
+	now := time.Unix(0, 0)
+	l := ratelimit.New(1, 2, ratelimit.WithClock(func() time.Time { return now }))
+
+	tests := []struct {
+		name          string
+		key           string
+		advance       time.Duration
+		wantAllowed   bool
+		wantRemaining int
+		wantRetry     time.Duration
+	}{
+		{"first request", "a", 0, true, 1, 0},
+		{"second request", "a", 0, true, 0, 0},
+		{"burst exhausted", "a", 0, false, 0, time.Second},
+		{"other key has own bucket", "b", 0, true, 1, 0},
+		{"partially refilled", "a", 500 * time.Millisecond, false, 0, 500 * time.Millisecond},
+		{"refilled token", "a", 500 * time.Millisecond, true, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now = now.Add(tt.advance)
+
+			got := l.Allow(tt.key)
+			if got.Allowed != tt.wantAllowed {
+				t.Errorf("Allow() allowed = %v, want %v", got.Allowed, tt.wantAllowed)
+			}
+			if got.Remaining != tt.wantRemaining {
+				t.Errorf("Allow() remaining = %d, want %d", got.Remaining, tt.wantRemaining)
+			}
+			if got.RetryAfter != tt.wantRetry {
+				t.Errorf("Allow() retry after = %v, want %v", got.RetryAfter, tt.wantRetry)
+			}
+			if got.Limit != 2 {
+				t.Errorf("Allow() limit = %d, want 2", got.Limit)
+			}
+		})
+	}
+}
+
+func TestLimiter_AllowN(t *testing.T) {
+	now := time.Unix(0, 0)
+	l := ratelimit.New(1, 3, ratelimit.WithClock(func() time.Time { return now }))
+
+	if got := l.AllowN("a", 2); !got.Allowed || got.Remaining != 1 {
+		t.Errorf("AllowN(2) = %+v, want allowed with 1 remaining", got)
+	}
+
+	got := l.AllowN("a", 2)
+	if got.Allowed || got.Remaining != 1 || got.RetryAfter != time.Second {
+		t.Errorf("AllowN(2) = %+v, want rejected without taking tokens", got)
+	}
+}
+
+func TestLimiter_Delay(t *testing.T) {
+	now := time.Unix(0, 0)
+	l := ratelimit.New(2, 1, ratelimit.WithClock(func() time.Time { return now }))
+
+	if got := l.Delay("a"); got != 0 {
+		t.Errorf("Delay() = %v, want 0 with a token left", got)
+	}
+
+	l.Allow("a")
+	if got := l.Delay("a"); got != 500*time.Millisecond {
+		t.Errorf("Delay() = %v, want 500ms", got)
+	}
+	if got := l.Delay("a"); got != 500*time.Millisecond {
+		t.Errorf("Delay() = %v, want it not to take a token", got)
+	}
+}
+
+func TestLimiter_Wait(t *testing.T) {
+	now := time.Unix(0, 0)
+	l := ratelimit.New(10, 1, ratelimit.WithClock(func() time.Time { return now }))
+
+	if err := l.Wait(context.Background(), "a"); err != nil {
+		t.Fatalf("Wait() error = %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := l.Wait(ctx, "a"); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Wait() error = %v, want %v", err, context.Canceled)
+	}
+
+	start := time.Now()
+	if err := l.Wait(context.Background(), "a"); err != nil {
+		t.Fatalf("Wait() error = %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond {
+		t.Errorf("Wait() returned after %v, want it to wait for the next token", elapsed)
+	}
+}
+
+func TestLimiter_Middleware(t *testing.T) {
+	l := ratelimit.New(0.5, 1)
+	h := l.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	serve := func(r *http.Request) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, r)
+		return rr
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/task", nil)
+	req.RemoteAddr = "192.0.2.1:1234"
+
+	rr := serve(req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", rr.Code, http.StatusOK)
+	}
+	if got := rr.Header().Get("X-RateLimit-Remaining"); got != "0" {
+		t.Errorf("X-RateLimit-Remaining = %q, want %q", got, "0")
+	}
+
+	req.RemoteAddr = "192.0.2.1:5678"
+	rr = serve(req)
+	if rr.Code != http.StatusTooManyRequests {
+		t.Fatalf("status = %d, want %d", rr.Code, http.StatusTooManyRequests)
+	}
+	if got := rr.Header().Get("Retry-After"); got != "2" {
+		t.Errorf("Retry-After = %q, want %q", got, "2")
+	}
+	if got := rr.Header().Get("X-RateLimit-Limit"); got != "1" {
+		t.Errorf("X-RateLimit-Limit = %q, want %q", got, "1")
+	}
+
+	rr = serve(req.WithContext(auth.WithClientID(req.Context(), "alice")))
+	if rr.Code != http.StatusOK {
+		t.Errorf("status for authenticated client = %d, want %d", rr.Code, http.StatusOK)
+	}
+}
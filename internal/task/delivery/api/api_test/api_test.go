@@ -1,16 +1,22 @@
 package api_test
 
 import (
+	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"reflect"
+	"strings"
 	"sync"
 	"testing"
 	"time"
 
+	"github.com/Mi7teR/aggregator/internal/auth"
+	"github.com/Mi7teR/aggregator/internal/policy"
+	"github.com/Mi7teR/aggregator/internal/ratelimit"
 	"github.com/Mi7teR/aggregator/internal/task/delivery/api"
 	"github.com/Mi7teR/aggregator/internal/task/entity"
 	"github.com/Mi7teR/aggregator/internal/task/repository"
@@ -109,9 +115,17 @@ func TestTask(t *testing.T) {
 
 		var responseJSON entity.TaskResult
 
+		created, err := repo.GetByID(context.Background(), id)
+		if err != nil {
+			t.Errorf("expected to get task result, got %v", err)
+		}
+
 		expectedResult := entity.TaskResult{
 			ID:             id,
 			Status:         entity.TaskStatusDone,
+			CreatedAt:      created.CreatedAt,
+			StartedAt:      created.StartedAt,
+			FinishedAt:     created.FinishedAt,
 			HTTPStatusCode: http.StatusOK,
 			Headers: map[string][]string{
 				"Content-Length": {
@@ -124,7 +138,10 @@ func TestTask(t *testing.T) {
 					time.Now().Add(-time.Second).UTC().Format(http.TimeFormat),
 				},
 			},
-			Length: 10,
+			Length:       10,
+			AttemptCount: 1,
+			Attempts:     []entity.TaskAttempt{{HTTPStatusCode: http.StatusOK}},
+			Timing:       created.Timing,
 		}
 
 		err = json.NewDecoder(res.Body).Decode(&responseJSON)
@@ -137,3 +154,798 @@ func TestTask(t *testing.T) {
 		}
 	})
 }
+
+func TestTaskBody(t *testing.T) {
+	repo := repository.NewTaskInMemoryRepository()
+	s := service.NewService(repo, time.Second*30)
+	h := api.NewHandler(s)
+	r := api.NewRouter(h)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/csv")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("a,b\n1,2\n")) //nolint:errcheck // we dont test it :)
+	}))
+	defer server.Close()
+
+	task := &entity.Task{Method: entity.MethodGet, URL: server.URL, CaptureBody: true}
+	id, err := repo.Create(context.Background(), task)
+	if err != nil {
+		t.Errorf("expected to create task, got %v", err)
+	}
+
+	s.Execute(id, task)
+
+	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("/task/%s/body", id), nil)
+	if err != nil {
+		t.Errorf("expected to create request, got %v", err)
+	}
+
+	res := executeRequest(req, r)
+
+	checkResponseCode(t, http.StatusOK, res.Code)
+
+	if ct := res.Header().Get("Content-Type"); ct != "text/csv" {
+		t.Errorf("expected Content-Type text/csv, got %s", ct)
+	}
+
+	if v := res.Header().Get("X-Content-Type-Options"); v != "nosniff" {
+		t.Errorf("expected X-Content-Type-Options nosniff, got %q", v)
+	}
+
+	if v := res.Header().Get("Content-Disposition"); v != "attachment" {
+		t.Errorf("expected Content-Disposition attachment, got %q", v)
+	}
+
+	if body := res.Body.String(); body != "a,b\n1,2\n" {
+		t.Errorf("expected captured body, got %q", body)
+	}
+
+	req, err = http.NewRequest(http.MethodGet, fmt.Sprintf("/task/%s/body", uuid.New()), nil)
+	if err != nil {
+		t.Errorf("expected to create request, got %v", err)
+	}
+
+	checkResponseCode(t, http.StatusNotFound, executeRequest(req, r).Code)
+}
+
+func TestCancelTask(t *testing.T) {
+	repo := repository.NewTaskInMemoryRepository()
+	s := service.NewService(repo, time.Second*30)
+	h := api.NewHandler(s)
+	r := api.NewRouter(h)
+
+	pendingID, err := repo.Create(context.Background(), &entity.Task{})
+	if err != nil {
+		t.Errorf("expected to create task, got %v", err)
+	}
+
+	doneID, err := repo.Create(context.Background(), &entity.Task{})
+	if err != nil {
+		t.Errorf("expected to create task, got %v", err)
+	}
+	if err = repo.Update(context.Background(), &entity.TaskResult{ID: doneID, Status: entity.TaskStatusDone}); err != nil {
+		t.Errorf("expected to update task, got %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		id       string
+		wantCode int
+	}{
+		{"cancel pending task", pendingID, http.StatusOK},
+		{"cancel finished task", doneID, http.StatusConflict},
+		{"cancel non-existent task", uuid.New().String(), http.StatusNotFound},
+		{"cancel invalid task id", "invalid-id", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, errReq := http.NewRequest(http.MethodPost, fmt.Sprintf("/task/%s/cancel", tt.id), nil)
+			if errReq != nil {
+				t.Errorf("expected to create request, got %v", errReq)
+			}
+
+			checkResponseCode(t, tt.wantCode, executeRequest(req, r).Code)
+		})
+	}
+}
+
+func TestAddTaskEgressPolicy(t *testing.T) {
+	p := &policy.Policy{DeniedNets: policy.PrivateNets()}
+	s := service.NewService(repository.NewTaskInMemoryRepository(), time.Second*30, service.WithEgressPolicy(p))
+	r := api.NewRouter(api.NewHandler(s))
+
+	body := bytes.NewBufferString(`{"method":"GET","url":"http://169.254.169.254/latest/meta-data"}`)
+
+	req, err := http.NewRequest(http.MethodPost, "/task", body)
+	if err != nil {
+		t.Fatalf("expected to create request, got %v", err)
+	}
+
+	resp := executeRequest(req, r)
+	checkResponseCode(t, http.StatusUnprocessableEntity, resp.Code)
+
+	var errResp entity.ErrorResponse
+	if err = json.NewDecoder(resp.Body).Decode(&errResp); err != nil {
+		t.Fatalf("expected to decode response, got %v", err)
+	}
+	if !strings.Contains(errResp.Error, policy.ErrDestinationNotAllowed.Error()) {
+		t.Errorf("expected error to mention %q, got %q", policy.ErrDestinationNotAllowed, errResp.Error)
+	}
+}
+
+func TestAddTaskValidation(t *testing.T) {
+	repo := repository.NewTaskInMemoryRepository()
+	s := service.NewService(repo, time.Second*30)
+	r := api.NewRouter(api.NewHandler(s))
+
+	body := bytes.NewBufferString(`{"method":"GET","url":"ftp://example.com/file","headers":{"Bad Header":"value"}}`)
+
+	req, err := http.NewRequest(http.MethodPost, "/task", body)
+	if err != nil {
+		t.Fatalf("expected to create request, got %v", err)
+	}
+
+	resp := executeRequest(req, r)
+	checkResponseCode(t, http.StatusUnprocessableEntity, resp.Code)
+
+	var errResp entity.ErrorResponse
+	if err = json.NewDecoder(resp.Body).Decode(&errResp); err != nil {
+		t.Fatalf("expected to decode response, got %v", err)
+	}
+
+	fields := make([]string, 0, len(errResp.Fields))
+	for _, f := range errResp.Fields {
+		fields = append(fields, f.Field)
+	}
+	if want := []string{"url", "headers[Bad Header]"}; !reflect.DeepEqual(fields, want) {
+		t.Errorf("expected fields %v, got %v", want, fields)
+	}
+
+	list, err := repo.List(context.Background(), &entity.TaskFilter{})
+	if err != nil {
+		t.Fatalf("expected to list tasks, got %v", err)
+	}
+	if len(list.Items) != 0 {
+		t.Errorf("expected no stored tasks, got %d", len(list.Items))
+	}
+}
+
+func TestGetTaskResultWait(t *testing.T) {
+	repo := repository.NewTaskInMemoryRepository()
+	s := service.NewService(repo, time.Second*30)
+	h := api.NewHandler(s)
+	r := api.NewRouter(h)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(time.Millisecond * 50)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	id, err := s.AddTask(context.Background(), &entity.Task{Method: entity.MethodGet, URL: server.URL})
+	if err != nil {
+		t.Fatalf("expected to add task, got %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		wait       string
+		wantCode   int
+		wantStatus entity.TaskResultStatus
+	}{
+		{"invalid wait", "soon", http.StatusBadRequest, 0},
+		{"negative wait", "-1s", http.StatusBadRequest, 0},
+		{"wait for completion", "10s", http.StatusOK, entity.TaskStatusDone},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, errReq := http.NewRequest(http.MethodGet, fmt.Sprintf("/task/%s?wait=%s", id, tt.wait), nil)
+			if errReq != nil {
+				t.Errorf("expected to create request, got %v", errReq)
+			}
+
+			resp := executeRequest(req, r)
+			checkResponseCode(t, tt.wantCode, resp.Code)
+
+			if tt.wantStatus == 0 {
+				return
+			}
+
+			var res entity.TaskResult
+			if errDecode := json.NewDecoder(resp.Body).Decode(&res); errDecode != nil {
+				t.Fatalf("expected to decode result, got %v", errDecode)
+			}
+			if res.Status != tt.wantStatus {
+				t.Errorf("expected status %v, got %v", tt.wantStatus, res.Status)
+			}
+		})
+	}
+}
+
+func TestAddTaskSync(t *testing.T) {
+	repo := repository.NewTaskInMemoryRepository()
+	s := service.NewService(repo, time.Second*30, service.WithWorkers(1))
+	h := api.NewHandler(s)
+	r := api.NewRouter(h)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	started := make(chan struct{}, 1)
+	release := make(chan struct{})
+	slowServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		<-release
+	}))
+	defer slowServer.Close()
+	defer close(release)
+
+	task := fmt.Sprintf(`{"method":"GET","url":%q}`, server.URL)
+
+	tests := []struct {
+		name           string
+		query          string
+		body           string
+		blockWorker    bool
+		wantCode       int
+		wantStatus     entity.TaskResultStatus
+		wantStatusCode int
+	}{
+		{"sync task", "?sync=true", task, false, http.StatusOK, entity.TaskStatusDone, http.StatusCreated},
+		{"invalid sync", "?sync=maybe", task, false, http.StatusBadRequest, 0, 0},
+		{
+			name:        "sync task deadline passes",
+			query:       "?sync=1",
+			body:        fmt.Sprintf(`{"method":"GET","url":%q,"timeouts":{"total":"50ms"}}`, server.URL),
+			blockWorker: true,
+			wantCode:    http.StatusAccepted,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.blockWorker {
+				blocking := &entity.Task{Method: entity.MethodGet, URL: slowServer.URL}
+				if _, err := s.AddTask(context.Background(), blocking); err != nil {
+					t.Fatalf("expected to add task, got %v", err)
+				}
+				<-started
+			}
+
+			req, errReq := http.NewRequest(http.MethodPost, "/task"+tt.query, bytes.NewBufferString(tt.body))
+			if errReq != nil {
+				t.Errorf("expected to create request, got %v", errReq)
+			}
+
+			resp := executeRequest(req, r)
+			checkResponseCode(t, tt.wantCode, resp.Code)
+
+			if tt.wantCode == http.StatusBadRequest {
+				return
+			}
+
+			var res entity.TaskResult
+			if errDecode := json.NewDecoder(resp.Body).Decode(&res); errDecode != nil {
+				t.Fatalf("expected to decode result, got %v", errDecode)
+			}
+			if res.ID == "" {
+				t.Errorf("expected task id in response")
+			}
+			if res.Status != tt.wantStatus || res.HTTPStatusCode != tt.wantStatusCode {
+				t.Errorf("expected status %v with code %d, got %v with code %d",
+					tt.wantStatus, tt.wantStatusCode, res.Status, res.HTTPStatusCode)
+			}
+		})
+	}
+}
+
+func TestTaskEvents(t *testing.T) {
+	repo := repository.NewTaskInMemoryRepository()
+	s := service.NewService(repo, time.Second*30)
+	h := api.NewHandler(s)
+	apiServer := httptest.NewServer(api.NewRouter(h))
+	defer apiServer.Close()
+
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	id, err := s.AddTask(context.Background(), &entity.Task{Method: entity.MethodGet, URL: server.URL})
+	if err != nil {
+		t.Fatalf("expected to add task, got %v", err)
+	}
+
+	taskResp, err := http.Get(fmt.Sprintf("%s/task/%s/events", apiServer.URL, id))
+	if err != nil {
+		t.Fatalf("expected to open task stream, got %v", err)
+	}
+	defer taskResp.Body.Close()
+
+	allResp, err := http.Get(fmt.Sprintf("%s/events?status=done&id=%s", apiServer.URL, id))
+	if err != nil {
+		t.Fatalf("expected to open events stream, got %v", err)
+	}
+	defer allResp.Body.Close()
+
+	for _, resp := range []*http.Response{taskResp, allResp} {
+		checkResponseCode(t, http.StatusOK, resp.StatusCode)
+		if ct := resp.Header.Get("content-type"); ct != "text/event-stream" {
+			t.Errorf("expected content type text/event-stream, got %q", ct)
+		}
+	}
+
+	taskEvents := bufio.NewScanner(taskResp.Body)
+	if first := nextEvent(taskEvents); first == "" || first == "done" {
+		t.Errorf("expected non-terminal first event, got %q", first)
+	}
+
+	close(release)
+
+	var last string
+	for event := nextEvent(taskEvents); event != ""; event = nextEvent(taskEvents) {
+		last = event
+	}
+	if last != "done" {
+		t.Errorf("expected task stream to end with done, got %q", last)
+	}
+
+	if event := nextEvent(bufio.NewScanner(allResp.Body)); event != "done" {
+		t.Errorf("expected filtered stream to receive done, got %q", event)
+	}
+
+	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("/task/%s/events", uuid.New().String()), nil)
+	if err != nil {
+		t.Fatalf("expected to create request, got %v", err)
+	}
+	checkResponseCode(t, http.StatusNotFound, executeRequest(req, api.NewRouter(h)).Code)
+
+	req, err = http.NewRequest(http.MethodGet, "/events?status=unknown", nil)
+	if err != nil {
+		t.Fatalf("expected to create request, got %v", err)
+	}
+	checkResponseCode(t, http.StatusBadRequest, executeRequest(req, api.NewRouter(h)).Code)
+}
+
+// nextEvent returns the name of the next event in the stream or an empty
+// string once the stream ends.
+func nextEvent(scanner *bufio.Scanner) string {
+	for scanner.Scan() {
+		if name, ok := strings.CutPrefix(scanner.Text(), "event: "); ok {
+			return name
+		}
+	}
+
+	return ""
+}
+
+func TestDeleteTask(t *testing.T) {
+	repo := repository.NewTaskInMemoryRepository()
+	s := service.NewService(repo, time.Second*30)
+	h := api.NewHandler(s)
+	r := api.NewRouter(h)
+
+	pendingID, err := repo.Create(context.Background(), &entity.Task{})
+	if err != nil {
+		t.Errorf("expected to create task, got %v", err)
+	}
+
+	doneID, err := repo.Create(context.Background(), &entity.Task{})
+	if err != nil {
+		t.Errorf("expected to create task, got %v", err)
+	}
+	if err = repo.Update(context.Background(), &entity.TaskResult{ID: doneID, Status: entity.TaskStatusDone}); err != nil {
+		t.Errorf("expected to update task, got %v", err)
+	}
+
+	notifyingID, err := repo.Create(context.Background(), &entity.Task{})
+	if err != nil {
+		t.Errorf("expected to create task, got %v", err)
+	}
+	err = repo.Update(context.Background(), &entity.TaskResult{
+		ID:       notifyingID,
+		Status:   entity.TaskStatusDone,
+		Callback: &entity.CallbackDelivery{Status: entity.CallbackStatusPending},
+	})
+	if err != nil {
+		t.Errorf("expected to update task, got %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		id       string
+		wantCode int
+	}{
+		{"delete pending task", pendingID, http.StatusConflict},
+		{"delete task with pending callback", notifyingID, http.StatusConflict},
+		{"delete finished task", doneID, http.StatusNoContent},
+		{"delete deleted task", doneID, http.StatusNotFound},
+		{"delete invalid task id", "invalid-id", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, errReq := http.NewRequest(http.MethodDelete, fmt.Sprintf("/task/%s", tt.id), nil)
+			if errReq != nil {
+				t.Errorf("expected to create request, got %v", errReq)
+			}
+
+			checkResponseCode(t, tt.wantCode, executeRequest(req, r).Code)
+		})
+	}
+}
+
+func TestTaskOwnership(t *testing.T) {
+	repo := repository.NewTaskInMemoryRepository()
+	s := service.NewService(repo, time.Second*30)
+	a := auth.New(auth.WithKeys(map[string]string{"alice-key": "alice", "bob-key": "bob"}))
+	r := api.NewRouter(api.NewHandler(s), api.WithAuth(a.Middleware))
+
+	aliceID, err := repo.Create(context.Background(), &entity.Task{Owner: "key:alice"})
+	if err != nil {
+		t.Fatalf("expected to create task, got %v", err)
+	}
+	err = repo.Update(context.Background(), &entity.TaskResult{ID: aliceID, Status: entity.TaskStatusDone})
+	if err != nil {
+		t.Fatalf("expected to update task, got %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		key      string
+		method   string
+		path     string
+		wantCode int
+	}{
+		{"unauthenticated", "", http.MethodGet, "/task/" + aliceID, http.StatusUnauthorized},
+		{"owner reads task", "alice-key", http.MethodGet, "/task/" + aliceID, http.StatusOK},
+		{"other client reads task", "bob-key", http.MethodGet, "/task/" + aliceID, http.StatusNotFound},
+		{"other client cancels task", "bob-key", http.MethodPost, "/task/" + aliceID + "/cancel", http.StatusNotFound},
+		{"other client deletes task", "bob-key", http.MethodDelete, "/task/" + aliceID, http.StatusNotFound},
+		{"owner deletes task", "alice-key", http.MethodDelete, "/task/" + aliceID, http.StatusNoContent},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, errReq := http.NewRequest(tt.method, tt.path, nil)
+			if errReq != nil {
+				t.Fatalf("expected to create request, got %v", errReq)
+			}
+			if tt.key != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.key)
+			}
+
+			checkResponseCode(t, tt.wantCode, executeRequest(req, r).Code)
+		})
+	}
+
+	body := bytes.NewBufferString(`{"method":"GET","url":"http://example.com"}`)
+	req, err := http.NewRequest(http.MethodPost, "/task", body)
+	if err != nil {
+		t.Fatalf("expected to create request, got %v", err)
+	}
+	req.Header.Set("X-API-Key", "bob-key")
+	checkResponseCode(t, http.StatusOK, executeRequest(req, r).Code)
+
+	for key, want := range map[string]int{"alice-key": 0, "bob-key": 1} {
+		req, err = http.NewRequest(http.MethodGet, "/tasks", nil)
+		if err != nil {
+			t.Fatalf("expected to create request, got %v", err)
+		}
+		req.Header.Set("X-API-Key", key)
+
+		resp := executeRequest(req, r)
+		checkResponseCode(t, http.StatusOK, resp.Code)
+
+		var list entity.TaskList
+		if err = json.NewDecoder(resp.Body).Decode(&list); err != nil {
+			t.Fatalf("expected to decode response, got %v", err)
+		}
+		if len(list.Items) != want {
+			t.Errorf("expected %d tasks for %s, got %d", want, key, len(list.Items))
+		}
+	}
+}
+
+func TestTransportStats(t *testing.T) {
+	s := service.NewService(repository.NewTaskInMemoryRepository(), time.Second*30,
+		service.WithTransport(service.TransportConfig{MaxIdleConnsPerHost: 4}))
+	clients := auth.New(auth.WithKeys(map[string]string{"alice-key": "alice"}))
+	admins := auth.New(auth.WithKeys(map[string]string{"ops-key": "ops"}))
+
+	tests := []struct {
+		name     string
+		opts     []api.RouterOption
+		key      string
+		wantCode int
+	}{
+		{
+			"not served without admin auth",
+			[]api.RouterOption{api.WithAuth(clients.Middleware)},
+			"alice-key",
+			http.StatusNotFound,
+		},
+		{
+			"task client rejected",
+			[]api.RouterOption{api.WithAuth(clients.Middleware), api.WithAdminAuth(admins.Middleware)},
+			"alice-key",
+			http.StatusUnauthorized,
+		},
+		{
+			"admin allowed",
+			[]api.RouterOption{api.WithAuth(clients.Middleware), api.WithAdminAuth(admins.Middleware)},
+			"ops-key",
+			http.StatusOK,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := api.NewRouter(api.NewHandler(s), tt.opts...)
+
+			req, err := http.NewRequest(http.MethodGet, "/admin/transport", nil)
+			if err != nil {
+				t.Fatalf("expected to create request, got %v", err)
+			}
+			req.Header.Set("X-API-Key", tt.key)
+
+			resp := executeRequest(req, r)
+			checkResponseCode(t, tt.wantCode, resp.Code)
+			if tt.wantCode != http.StatusOK {
+				return
+			}
+
+			var stats entity.TransportStats
+			if err = json.NewDecoder(resp.Body).Decode(&stats); err != nil {
+				t.Fatalf("expected to decode response, got %v", err)
+			}
+			if stats.Settings.MaxIdleConnsPerHost != 4 || !stats.Settings.HTTP2 {
+				t.Errorf("expected configured transport settings, got %+v", stats.Settings)
+			}
+		})
+	}
+}
+
+func TestAddTaskTooLarge(t *testing.T) {
+	repo := repository.NewTaskInMemoryRepository()
+	s := service.NewService(repo, time.Second*30)
+	r := api.NewRouter(api.NewHandler(s))
+
+	task := func(size int) string {
+		return fmt.Sprintf(`{"method":"POST","url":"http://example.com","body":%q}`, strings.Repeat("a", size))
+	}
+
+	tests := []struct {
+		name        string
+		path        string
+		contentType string
+		body        string
+	}{
+		{"task", "/task", "application/json", task(17 << 20)},
+		{"ndjson batch line", "/tasks", "application/x-ndjson", task(2 << 20)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodPost, tt.path, strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatalf("expected to create request, got %v", err)
+			}
+			req.Header.Set("Content-Type", tt.contentType)
+
+			checkResponseCode(t, http.StatusRequestEntityTooLarge, executeRequest(req, r).Code)
+		})
+	}
+}
+
+func TestAddTasksRateLimit(t *testing.T) {
+	repo := repository.NewTaskInMemoryRepository()
+	s := service.NewService(repo, time.Second*30)
+	limiter := ratelimit.New(0.001, 3)
+	r := api.NewRouter(api.NewHandler(s), api.WithSubmitLimit(limiter))
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	task := fmt.Sprintf(`{"method":"GET","url":%q}`, server.URL)
+
+	tests := []struct {
+		name           string
+		tasks          int
+		wantCode       int
+		wantRetryAfter bool
+	}{
+		{"batch over burst", 4, http.StatusRequestEntityTooLarge, false},
+		{"rejected batch takes no tokens", 3, http.StatusOK, false},
+		{"tokens used up by the batch", 1, http.StatusTooManyRequests, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := "[" + strings.TrimSuffix(strings.Repeat(task+",", tt.tasks), ",") + "]"
+
+			req, err := http.NewRequest(http.MethodPost, "/tasks", strings.NewReader(body))
+			if err != nil {
+				t.Fatalf("expected to create request, got %v", err)
+			}
+			req.RemoteAddr = "192.0.2.1:1234"
+
+			resp := executeRequest(req, r)
+			checkResponseCode(t, tt.wantCode, resp.Code)
+			if got := resp.Header().Get("retry-after") != ""; got != tt.wantRetryAfter {
+				t.Errorf("expected retry-after %v, got %q", tt.wantRetryAfter, resp.Header().Get("retry-after"))
+			}
+		})
+	}
+}
+
+func TestAddTasksMaxBatchSize(t *testing.T) {
+	s := service.NewService(repository.NewTaskInMemoryRepository(), time.Second*30)
+	r := api.NewRouter(api.NewHandler(s, api.WithMaxBatchSize(2)))
+
+	task := `{"method":"GET","url":"http://127.0.0.1:1"}`
+
+	tests := []struct {
+		name     string
+		body     string
+		wantCode int
+	}{
+		{"within limit", fmt.Sprintf("[%s,%s]", task, task), http.StatusOK},
+		{"over limit", fmt.Sprintf("[%s,%s,%s]", task, task, task), http.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodPost, "/tasks", strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatalf("expected to create request, got %v", err)
+			}
+
+			checkResponseCode(t, tt.wantCode, executeRequest(req, r).Code)
+		})
+	}
+}
+
+func TestAddTasks(t *testing.T) {
+	repo := repository.NewTaskInMemoryRepository()
+	s := service.NewService(repo, time.Second*30)
+	h := api.NewHandler(s)
+	r := api.NewRouter(h)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	validTask := fmt.Sprintf(`{"method":"GET","url":%q}`, server.URL)
+	invalidTask := fmt.Sprintf(`{"method":"FETCH","url":%q}`, server.URL)
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		wantCode    int
+		wantErrors  []bool
+	}{
+		{
+			name:        "json array batch",
+			contentType: "application/json",
+			body:        fmt.Sprintf(`[%s, %s, %s]`, validTask, invalidTask, validTask),
+			wantCode:    http.StatusOK,
+			wantErrors:  []bool{false, true, false},
+		},
+		{
+			name:        "ndjson batch",
+			contentType: "application/x-ndjson",
+			body:        fmt.Sprintf("%s\n%s\n\n", invalidTask, validTask),
+			wantCode:    http.StatusOK,
+			wantErrors:  []bool{true, false},
+		},
+		{
+			name:        "not an array",
+			contentType: "application/json",
+			body:        validTask,
+			wantCode:    http.StatusBadRequest,
+		},
+		{
+			name:        "malformed array",
+			contentType: "application/json",
+			body:        fmt.Sprintf(`[%s, {`, validTask),
+			wantCode:    http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodPost, "/tasks", bytes.NewBufferString(tt.body))
+			if err != nil {
+				t.Errorf("expected to create request, got %v", err)
+			}
+			req.Header.Set("Content-Type", tt.contentType)
+
+			res := executeRequest(req, r)
+
+			checkResponseCode(t, tt.wantCode, res.Code)
+			if tt.wantCode != http.StatusOK {
+				return
+			}
+
+			var batch entity.BatchResponse
+			if err = json.NewDecoder(res.Body).Decode(&batch); err != nil {
+				t.Fatalf("expected to decode response, got %v", err)
+			}
+
+			if len(batch.Results) != len(tt.wantErrors) {
+				t.Fatalf("expected %d results, got %d", len(tt.wantErrors), len(batch.Results))
+			}
+
+			for i, item := range batch.Results {
+				if item.Index != i {
+					t.Errorf("expected result %d to have index %d, got %d", i, i, item.Index)
+				}
+				if (item.Error != "") != tt.wantErrors[i] {
+					t.Errorf("expected result %d error = %v, got %q", i, tt.wantErrors[i], item.Error)
+				}
+				if item.Error == "" {
+					if _, err = uuid.Parse(item.ID); err != nil {
+						t.Errorf("expected result %d to have valid uuid, got %q", i, item.ID)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestListTasks(t *testing.T) {
+	repo := repository.NewTaskInMemoryRepository()
+	s := service.NewService(repo, time.Second*30)
+	h := api.NewHandler(s)
+	r := api.NewRouter(h)
+
+	getID, _ := repo.Create(context.Background(), &entity.Task{Method: entity.MethodGet, URL: "https://example.com"})
+	postID, _ := repo.Create(context.Background(), &entity.Task{Method: entity.MethodPost, URL: "https://example.com"})
+
+	tests := []struct {
+		name     string
+		query    string
+		wantCode int
+		wantIDs  []string
+	}{
+		{"list all tasks", "", http.StatusOK, []string{getID, postID}},
+		{"list tasks by method", "?method=post", http.StatusOK, []string{postID}},
+		{"list tasks by status and host", "?status=new,done&host=example.com", http.StatusOK, []string{getID, postID}},
+		{"list tasks by other host", "?host=other.org", http.StatusOK, []string{}},
+		{"invalid status", "?status=finished", http.StatusBadRequest, nil},
+		{"invalid created_after", "?created_after=yesterday", http.StatusBadRequest, nil},
+		{"invalid limit", "?limit=-1", http.StatusBadRequest, nil},
+		{"invalid cursor", "?cursor=%21", http.StatusBadRequest, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, "/tasks"+tt.query, nil)
+			if err != nil {
+				t.Errorf("expected to create request, got %v", err)
+			}
+
+			res := executeRequest(req, r)
+
+			checkResponseCode(t, tt.wantCode, res.Code)
+			if tt.wantCode != http.StatusOK {
+				return
+			}
+
+			var list entity.TaskList
+			if err = json.NewDecoder(res.Body).Decode(&list); err != nil {
+				t.Fatalf("expected to decode response, got %v", err)
+			}
+
+			gotIDs := make([]string, 0, len(list.Items))
+			for _, item := range list.Items {
+				gotIDs = append(gotIDs, item.ID)
+			}
+
+			if !reflect.DeepEqual(gotIDs, tt.wantIDs) {
+				t.Errorf("ListTasks() got %v, want %v", gotIDs, tt.wantIDs)
+			}
+		})
+	}
+}
@@ -0,0 +1,141 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+
+	"github.com/Mi7teR/aggregator/internal/ratelimit"
+	"github.com/Mi7teR/aggregator/internal/task/entity"
+)
+
+const (
+	DefaultMaxBatchSize = 1000
+	maxNDJSONLineLen    = 1 << 20
+	maxBatchRequestSize = 64 << 20
+)
+
+var (
+	ErrBatchTooLarge = errors.New("batch exceeds the maximum number of tasks")
+	ErrBatchNotArray = errors.New("batch must be a json array")
+)
+
+func (h *Handler) AddTasks(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("content-type", "application/json")
+
+	var (
+		items []json.RawMessage
+		err   error
+	)
+
+	body := http.MaxBytesReader(w, r.Body, maxBatchRequestSize)
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("content-type"))
+	switch mediaType {
+	case "application/x-ndjson", "application/ndjson":
+		items, err = decodeNDJSON(body, h.maxBatchSize)
+	default:
+		items, err = decodeJSONArray(body, h.maxBatchSize)
+	}
+
+	if err != nil {
+		w.WriteHeader(decodeErrorStatus(err))
+		_ = json.NewEncoder(w).Encode(&entity.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	if !ratelimit.Charge(w, r, len(items)) {
+		return
+	}
+
+	res := entity.BatchResponse{Results: make([]entity.BatchItemResult, 0, len(items))}
+
+	for i, item := range items {
+		res.Results = append(res.Results, h.addBatchItem(r, i, item))
+	}
+
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(&res)
+}
+
+func (h *Handler) addBatchItem(r *http.Request, index int, item json.RawMessage) entity.BatchItemResult {
+	var task entity.Task
+	if err := json.Unmarshal(item, &task); err != nil {
+		return entity.BatchItemResult{Index: index, Error: err.Error()}
+	}
+
+	taskID, err := h.s.AddTask(r.Context(), &task)
+	if err != nil {
+		var validationErr *entity.ValidationError
+		if errors.As(err, &validationErr) {
+			return entity.BatchItemResult{Index: index, Error: err.Error(), Fields: validationErr.Fields}
+		}
+
+		return entity.BatchItemResult{Index: index, Error: err.Error()}
+	}
+
+	return entity.BatchItemResult{Index: index, ID: taskID}
+}
+
+func decodeJSONArray(body io.Reader, limit int) ([]json.RawMessage, error) {
+	dec := json.NewDecoder(body)
+
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	if delim, ok := tok.(json.Delim); !ok || delim != '[' {
+		return nil, ErrBatchNotArray
+	}
+
+	var items []json.RawMessage
+	for dec.More() {
+		if len(items) == limit {
+			return nil, fmt.Errorf("%w of %d", ErrBatchTooLarge, limit)
+		}
+
+		var item json.RawMessage
+		if err = dec.Decode(&item); err != nil {
+			return nil, err
+		}
+
+		items = append(items, item)
+	}
+
+	if _, err = dec.Token(); err != nil {
+		return nil, err
+	}
+
+	return items, nil
+}
+
+func decodeNDJSON(body io.Reader, limit int) ([]json.RawMessage, error) {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(nil, maxNDJSONLineLen)
+
+	var items []json.RawMessage
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		if len(items) == limit {
+			return nil, fmt.Errorf("%w of %d", ErrBatchTooLarge, limit)
+		}
+
+		items = append(items, append(json.RawMessage(nil), line...))
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return items, nil
+}
@@ -0,0 +1,180 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/Mi7teR/aggregator/internal/logger"
+	"github.com/Mi7teR/aggregator/internal/task/entity"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+const heartbeatInterval = 15 * time.Second
+
+var ErrStreamingUnsupported = errors.New("streaming unsupported")
+
+// TaskEvents streams the status changes of a single task, starting with its
+// current state, and ends once the task reaches a terminal status.
+func (h *Handler) TaskEvents(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("content-type", "application/json")
+
+	id := chi.URLParam(r, "id")
+	if _, err := uuid.Parse(id); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(w).Encode(&entity.ErrorResponse{Error: fmt.Errorf("uuid parse: %w", err).Error()})
+		return
+	}
+
+	ctx := logger.WithTaskID(r.Context(), id)
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		w.WriteHeader(http.StatusInternalServerError)
+		_ = json.NewEncoder(w).Encode(&entity.ErrorResponse{Error: ErrStreamingUnsupported.Error()})
+		return
+	}
+
+	res, updates, unsubscribe, err := h.s.WatchTask(ctx, id)
+	if err != nil {
+		if isNotFound(err) {
+			w.WriteHeader(http.StatusNotFound)
+			_ = json.NewEncoder(w).Encode(&entity.ErrorResponse{Error: err.Error()})
+			return
+		}
+
+		slog.ErrorContext(ctx, "watch task", "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		_ = json.NewEncoder(w).Encode(&entity.ErrorResponse{Error: err.Error()})
+		return
+	}
+	defer unsubscribe()
+
+	startStream(w)
+	if err = writeEvent(w, res); err != nil {
+		return
+	}
+	flusher.Flush()
+
+	if res.Status.Terminal() {
+		return
+	}
+
+	stream(w, r, flusher, updates, func(update *entity.TaskResult) bool {
+		return update.Status.Terminal()
+	})
+}
+
+// Events streams the status changes of all tasks matching the status and id
+// query filters. Events are dropped for clients that do not keep up.
+func (h *Handler) Events(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("content-type", "application/json")
+
+	filter, err := parseEventFilter(r.URL.Query())
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(w).Encode(&entity.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		w.WriteHeader(http.StatusInternalServerError)
+		_ = json.NewEncoder(w).Encode(&entity.ErrorResponse{Error: ErrStreamingUnsupported.Error()})
+		return
+	}
+
+	updates, unsubscribe := h.s.Subscribe(r.Context(), filter)
+	defer unsubscribe()
+
+	startStream(w)
+	flusher.Flush()
+
+	stream(w, r, flusher, updates, func(*entity.TaskResult) bool {
+		return false
+	})
+}
+
+func parseEventFilter(q url.Values) (*entity.EventFilter, error) {
+	filter := &entity.EventFilter{}
+
+	for _, v := range queryList(q, "id") {
+		if _, err := uuid.Parse(v); err != nil {
+			return nil, fmt.Errorf("id %q: %w", v, err)
+		}
+
+		filter.IDs = append(filter.IDs, v)
+	}
+
+	for _, v := range queryList(q, "status") {
+		status, err := entity.ParseTaskResultStatus(v)
+		if err != nil {
+			return nil, fmt.Errorf("status %q: %w", v, err)
+		}
+
+		filter.Statuses = append(filter.Statuses, status)
+	}
+
+	return filter, nil
+}
+
+func startStream(w http.ResponseWriter) {
+	w.Header().Set("content-type", "text/event-stream")
+	w.Header().Set("cache-control", "no-cache")
+	w.Header().Set("connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+}
+
+// stream writes updates as events until the client goes away, the
+// subscription is closed or last reports true for a written event.
+func stream(
+	w http.ResponseWriter,
+	r *http.Request,
+	flusher http.Flusher,
+	updates <-chan entity.TaskResult,
+	last func(update *entity.TaskResult) bool,
+) {
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case update, ok := <-updates:
+			if !ok {
+				return
+			}
+
+			if err := writeEvent(w, &update); err != nil {
+				return
+			}
+			flusher.Flush()
+
+			if last(&update) {
+				return
+			}
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func writeEvent(w http.ResponseWriter, res *entity.TaskResult) error {
+	data, err := json.Marshal(res)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", res.Status.String(), data)
+
+	return err
+}
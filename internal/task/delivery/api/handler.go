@@ -1,11 +1,16 @@
 package api
 
 import (
+	"bufio"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"log/slog"
 	"net/http"
+	"strconv"
+	"time"
 
+	"github.com/Mi7teR/aggregator/internal/logger"
 	"github.com/Mi7teR/aggregator/internal/task/entity"
 	"github.com/Mi7teR/aggregator/internal/task/repository"
 	"github.com/Mi7teR/aggregator/internal/task/service"
@@ -13,36 +18,150 @@ import (
 	"github.com/google/uuid"
 )
 
+const (
+	retryAfterSeconds = 1
+	maxWait           = time.Minute
+
+	// maxTaskRequestSize leaves room for a base64 encoded payload of
+	// entity.MaxPayloadSize next to the other task fields.
+	maxTaskRequestSize = 16 << 20
+)
+
+var ErrNegativeWait = errors.New("wait must not be negative")
+
 type Handler struct {
-	s *service.Service
+	s            *service.Service
+	maxBatchSize int
 }
 
-func NewHandler(s *service.Service) *Handler {
-	return &Handler{s: s}
+type HandlerOption func(h *Handler)
+
+// WithMaxBatchSize limits the number of tasks a single batch submission may
+// hold. When submissions are rate limited, a batch also has to fit the rate
+// limit burst, as it is charged a token per task.
+func WithMaxBatchSize(n int) HandlerOption {
+	return func(h *Handler) {
+		if n > 0 {
+			h.maxBatchSize = n
+		}
+	}
+}
+
+func NewHandler(s *service.Service, opts ...HandlerOption) *Handler {
+	h := &Handler{s: s, maxBatchSize: DefaultMaxBatchSize}
+	for _, opt := range opts {
+		opt(h)
+	}
+
+	return h
 }
 
 func (h *Handler) AddTask(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("content-type", "application/json")
 
-	var req entity.Task
-	err := json.NewDecoder(r.Body).Decode(&req)
+	sync, err := parseSync(r.URL.Query().Get("sync"))
 	if err != nil {
 		w.WriteHeader(http.StatusBadRequest)
 		_ = json.NewEncoder(w).Encode(&entity.ErrorResponse{Error: err.Error()})
 		return
 	}
 
-	taskID, err := h.s.AddTask(r.Context(), &req)
+	var req entity.Task
+	err = json.NewDecoder(http.MaxBytesReader(w, r.Body, maxTaskRequestSize)).Decode(&req)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+		w.WriteHeader(decodeErrorStatus(err))
 		_ = json.NewEncoder(w).Encode(&entity.ErrorResponse{Error: err.Error()})
 		return
 	}
 
+	if sync {
+		h.runTask(w, r, &req)
+		return
+	}
+
+	taskID, err := h.s.AddTask(r.Context(), &req)
+	if err != nil {
+		writeAddTaskError(w, r, err)
+		return
+	}
+
 	w.WriteHeader(http.StatusOK)
 	_ = json.NewEncoder(w).Encode(&entity.TaskResult{ID: taskID})
 }
 
+// runTask responds with the final task result, or with 202 and the task ID
+// when the task does not finish within its timeout.
+func (h *Handler) runTask(w http.ResponseWriter, r *http.Request, task *entity.Task) {
+	res, err := h.s.RunTask(r.Context(), task)
+	if err != nil {
+		writeAddTaskError(w, r, err)
+		return
+	}
+
+	if !res.Status.Terminal() {
+		w.WriteHeader(http.StatusAccepted)
+		_ = json.NewEncoder(w).Encode(&entity.TaskResult{ID: res.ID})
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(res)
+}
+
+// decodeErrorStatus returns 413 when the request body failed to decode
+// because it is over its size limit, and 400 otherwise.
+func decodeErrorStatus(err error) int {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) || errors.Is(err, bufio.ErrTooLong) || errors.Is(err, ErrBatchTooLarge) {
+		return http.StatusRequestEntityTooLarge
+	}
+
+	return http.StatusBadRequest
+}
+
+// writeAddTaskError responds to a task that could not be added: 422 for
+// validation errors, 503 and 429 with retry-after when the service or the
+// client is at capacity.
+func writeAddTaskError(w http.ResponseWriter, r *http.Request, err error) {
+	var validationErr *entity.ValidationError
+	if errors.As(err, &validationErr) {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		_ = json.NewEncoder(w).Encode(&entity.ErrorResponse{Error: err.Error(), Fields: validationErr.Fields})
+		return
+	}
+
+	if errors.Is(err, service.ErrQueueFull) || errors.Is(err, service.ErrShutdown) {
+		w.Header().Set("retry-after", strconv.Itoa(retryAfterSeconds))
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_ = json.NewEncoder(w).Encode(&entity.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	if errors.Is(err, service.ErrQuotaExceeded) {
+		w.Header().Set("retry-after", strconv.Itoa(retryAfterSeconds))
+		w.WriteHeader(http.StatusTooManyRequests)
+		_ = json.NewEncoder(w).Encode(&entity.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	slog.ErrorContext(r.Context(), "add task", "error", err)
+	w.WriteHeader(http.StatusInternalServerError)
+	_ = json.NewEncoder(w).Encode(&entity.ErrorResponse{Error: err.Error()})
+}
+
+func parseSync(v string) (bool, error) {
+	if v == "" {
+		return false, nil
+	}
+
+	sync, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, fmt.Errorf("sync: %w", err)
+	}
+
+	return sync, nil
+}
+
 func (h *Handler) GetTaskResult(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("content-type", "application/json")
 
@@ -53,14 +172,30 @@ func (h *Handler) GetTaskResult(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	res, err := h.s.GetTaskResult(r.Context(), id)
+	ctx := logger.WithTaskID(r.Context(), id)
+
+	wait, err := parseWait(r.URL.Query().Get("wait"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(w).Encode(&entity.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	var res *entity.TaskResult
+	if wait > 0 {
+		res, err = h.s.WaitTaskResult(ctx, id, wait)
+	} else {
+		res, err = h.s.GetTaskResult(ctx, id)
+	}
+
 	if err != nil {
-		if errors.Is(err, repository.ErrNotFound) {
+		if isNotFound(err) {
 			w.WriteHeader(http.StatusNotFound)
 			_ = json.NewEncoder(w).Encode(&entity.ErrorResponse{Error: err.Error()})
 			return
 		}
 
+		slog.ErrorContext(ctx, "get task result", "error", err)
 		w.WriteHeader(http.StatusInternalServerError)
 		_ = json.NewEncoder(w).Encode(&entity.ErrorResponse{Error: err.Error()})
 		return
@@ -70,6 +205,169 @@ func (h *Handler) GetTaskResult(w http.ResponseWriter, r *http.Request) {
 	_ = json.NewEncoder(w).Encode(res)
 }
 
+// parseWait parses the wait query parameter, capping it at maxWait.
+func parseWait(v string) (time.Duration, error) {
+	if v == "" {
+		return 0, nil
+	}
+
+	wait, err := time.ParseDuration(v)
+	if err != nil {
+		return 0, fmt.Errorf("wait: %w", err)
+	}
+
+	if wait < 0 {
+		return 0, ErrNegativeWait
+	}
+
+	if wait > maxWait {
+		wait = maxWait
+	}
+
+	return wait, nil
+}
+
+func (h *Handler) GetTaskBody(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if _, err := uuid.Parse(id); err != nil {
+		w.Header().Set("content-type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(w).Encode(&entity.ErrorResponse{Error: fmt.Errorf("uuid parse: %w", err).Error()})
+		return
+	}
+
+	ctx := logger.WithTaskID(r.Context(), id)
+
+	res, err := h.s.GetTaskBody(ctx, id)
+	if err != nil {
+		w.Header().Set("content-type", "application/json")
+
+		if isNotFound(err) || errors.Is(err, service.ErrBodyNotCaptured) {
+			w.WriteHeader(http.StatusNotFound)
+			_ = json.NewEncoder(w).Encode(&entity.ErrorResponse{Error: err.Error()})
+			return
+		}
+
+		slog.ErrorContext(ctx, "get task body", "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		_ = json.NewEncoder(w).Encode(&entity.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	contentType := res.Headers.Get("content-type")
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	// The body is upstream content served from our origin, so browsers must
+	// neither sniff nor render it.
+	w.Header().Set("content-type", contentType)
+	w.Header().Set("content-length", strconv.Itoa(len(res.Body)))
+	w.Header().Set("x-content-type-options", "nosniff")
+	w.Header().Set("content-disposition", "attachment")
+	w.Header().Set("content-security-policy", "sandbox")
+	if res.BodyTruncated {
+		w.Header().Set("x-body-truncated", "true")
+	}
+
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(res.Body)
+}
+
+func (h *Handler) CancelTask(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("content-type", "application/json")
+
+	id := chi.URLParam(r, "id")
+	if _, err := uuid.Parse(id); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(w).Encode(&entity.ErrorResponse{Error: fmt.Errorf("uuid parse: %w", err).Error()})
+		return
+	}
+
+	ctx := logger.WithTaskID(r.Context(), id)
+
+	res, err := h.s.CancelTask(ctx, id)
+	if err != nil {
+		if isNotFound(err) {
+			w.WriteHeader(http.StatusNotFound)
+			_ = json.NewEncoder(w).Encode(&entity.ErrorResponse{Error: err.Error()})
+			return
+		}
+
+		if errors.Is(err, service.ErrTaskFinished) {
+			w.WriteHeader(http.StatusConflict)
+			_ = json.NewEncoder(w).Encode(&entity.ErrorResponse{
+				Error: fmt.Sprintf("%s with status %s", err, res.Status.String()),
+			})
+			return
+		}
+
+		slog.ErrorContext(ctx, "cancel task", "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		_ = json.NewEncoder(w).Encode(&entity.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(res)
+}
+
+func (h *Handler) DeleteTask(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("content-type", "application/json")
+
+	id := chi.URLParam(r, "id")
+	if _, err := uuid.Parse(id); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(w).Encode(&entity.ErrorResponse{Error: fmt.Errorf("uuid parse: %w", err).Error()})
+		return
+	}
+
+	ctx := logger.WithTaskID(r.Context(), id)
+
+	res, err := h.s.DeleteTask(ctx, id)
+	if err != nil {
+		if isNotFound(err) {
+			w.WriteHeader(http.StatusNotFound)
+			_ = json.NewEncoder(w).Encode(&entity.ErrorResponse{Error: err.Error()})
+			return
+		}
+
+		if errors.Is(err, service.ErrTaskNotFinished) {
+			w.WriteHeader(http.StatusConflict)
+			_ = json.NewEncoder(w).Encode(&entity.ErrorResponse{
+				Error: fmt.Sprintf("%s, status %s", err, res.Status.String()),
+			})
+			return
+		}
+
+		if errors.Is(err, service.ErrCallbackPending) {
+			w.WriteHeader(http.StatusConflict)
+			_ = json.NewEncoder(w).Encode(&entity.ErrorResponse{Error: err.Error()})
+			return
+		}
+
+		slog.ErrorContext(ctx, "delete task", "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		_ = json.NewEncoder(w).Encode(&entity.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// TransportStats reports the connection counters and settings of the
+// transport used for upstream requests.
+func (h *Handler) TransportStats(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("content-type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(h.s.TransportStats())
+}
+
+// isNotFound reports whether the task is missing or owned by another client.
+func isNotFound(err error) bool {
+	return errors.Is(err, repository.ErrNotFound) || errors.Is(err, service.ErrTaskNotFound)
+}
+
 func (h *Handler) NotFoundHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("content-type", "application/json")
 	w.WriteHeader(http.StatusNotFound)
@@ -0,0 +1,115 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Mi7teR/aggregator/internal/task/entity"
+	"github.com/Mi7teR/aggregator/internal/task/repository"
+)
+
+func (h *Handler) ListTasks(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("content-type", "application/json")
+
+	filter, err := parseTaskFilter(r.URL.Query())
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(w).Encode(&entity.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	list, err := h.s.ListTasks(r.Context(), filter)
+	if err != nil {
+		if errors.Is(err, repository.ErrInvalidCursor) {
+			w.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(w).Encode(&entity.ErrorResponse{Error: err.Error()})
+			return
+		}
+
+		slog.ErrorContext(r.Context(), "list tasks", "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		_ = json.NewEncoder(w).Encode(&entity.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(list)
+}
+
+func parseTaskFilter(q url.Values) (*entity.TaskFilter, error) {
+	filter := &entity.TaskFilter{
+		Host:   q.Get("host"),
+		Cursor: q.Get("cursor"),
+	}
+
+	for _, v := range queryList(q, "status") {
+		status, err := entity.ParseTaskResultStatus(v)
+		if err != nil {
+			return nil, fmt.Errorf("status %q: %w", v, err)
+		}
+
+		filter.Statuses = append(filter.Statuses, status)
+	}
+
+	for _, v := range queryList(q, "method") {
+		method, err := entity.ParseTaskMethod(strings.ToUpper(v))
+		if err != nil {
+			return nil, fmt.Errorf("method %q: %w", v, err)
+		}
+
+		filter.Methods = append(filter.Methods, method)
+	}
+
+	var err error
+	if filter.CreatedAfter, err = queryTime(q, "created_after"); err != nil {
+		return nil, err
+	}
+
+	if filter.CreatedBefore, err = queryTime(q, "created_before"); err != nil {
+		return nil, err
+	}
+
+	if v := q.Get("limit"); v != "" {
+		if filter.Limit, err = strconv.Atoi(v); err != nil || filter.Limit < 0 {
+			return nil, fmt.Errorf("limit %q: must be a non-negative integer", v)
+		}
+	}
+
+	return filter, nil
+}
+
+// queryList supports both repeated and comma separated query values.
+func queryList(q url.Values, key string) []string {
+	var values []string
+
+	for _, v := range q[key] {
+		for _, part := range strings.Split(v, ",") {
+			if part = strings.TrimSpace(part); part != "" {
+				values = append(values, part)
+			}
+		}
+	}
+
+	return values
+}
+
+func queryTime(q url.Values, key string) (time.Time, error) {
+	v := q.Get(key)
+	if v == "" {
+		return time.Time{}, nil
+	}
+
+	t, err := time.Parse(time.RFC3339Nano, v)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%s: %w", key, err)
+	}
+
+	return t, nil
+}
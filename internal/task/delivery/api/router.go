@@ -1,16 +1,105 @@
 package api
 
 import (
+	"net/http"
+
+	"github.com/Mi7teR/aggregator/internal/logger"
+	"github.com/Mi7teR/aggregator/internal/ratelimit"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 )
 
-func NewRouter(h *Handler) *chi.Mux {
+type RouterOption func(c *routerConfig)
+
+type routerConfig struct {
+	middlewares    []func(http.Handler) http.Handler
+	auth           func(http.Handler) http.Handler
+	adminAuth      func(http.Handler) http.Handler
+	submitLimit    *ratelimit.Limiter
+	metricsHandler http.Handler
+}
+
+func WithMiddleware(mw ...func(http.Handler) http.Handler) RouterOption {
+	return func(c *routerConfig) {
+		c.middlewares = append(c.middlewares, mw...)
+	}
+}
+
+// WithAuth protects the task routes with the authentication middleware.
+// The metrics endpoint stays public for scrapers.
+func WithAuth(mw func(http.Handler) http.Handler) RouterOption {
+	return func(c *routerConfig) {
+		c.auth = mw
+	}
+}
+
+// WithAdminAuth serves the admin endpoints behind their own authentication
+// middleware, separate from the task clients. Without it the admin endpoints
+// are not served at all.
+func WithAdminAuth(mw func(http.Handler) http.Handler) RouterOption {
+	return func(c *routerConfig) {
+		c.adminAuth = mw
+	}
+}
+
+// WithSubmitLimit rate limits the task submission routes. A batch is
+// charged a token per task. It runs after authentication, so it can key on
+// the client.
+func WithSubmitLimit(l *ratelimit.Limiter) RouterOption {
+	return func(c *routerConfig) {
+		c.submitLimit = l
+	}
+}
+
+func WithMetricsHandler(h http.Handler) RouterOption {
+	return func(c *routerConfig) {
+		c.metricsHandler = h
+	}
+}
+
+func NewRouter(h *Handler, opts ...RouterOption) *chi.Mux {
+	c := &routerConfig{}
+	for _, opt := range opts {
+		opt(c)
+	}
+
 	r := chi.NewRouter()
 
-	r.Use(middleware.Logger)
-	r.Post("/task", h.AddTask)
-	r.Get("/task/{id}", h.GetTaskResult)
+	r.Use(middleware.RequestID)
+	r.Use(logger.Middleware)
+	r.Use(c.middlewares...)
+	r.Group(func(r chi.Router) {
+		if c.auth != nil {
+			r.Use(c.auth)
+		}
+
+		submit, submitBatch := r, r
+		if c.submitLimit != nil {
+			submit = r.With(c.submitLimit.Middleware)
+			submitBatch = r.With(c.submitLimit.BatchMiddleware)
+		}
+
+		submit.Post("/task", h.AddTask)
+		submitBatch.Post("/tasks", h.AddTasks)
+		r.Get("/tasks", h.ListTasks)
+		r.Get("/events", h.Events)
+		r.Get("/task/{id}", h.GetTaskResult)
+		r.Delete("/task/{id}", h.DeleteTask)
+		r.Get("/task/{id}/body", h.GetTaskBody)
+		r.Get("/task/{id}/events", h.TaskEvents)
+		r.Post("/task/{id}/cancel", h.CancelTask)
+	})
+
+	if c.adminAuth != nil {
+		r.Group(func(r chi.Router) {
+			r.Use(c.adminAuth)
+			r.Get("/admin/transport", h.TransportStats)
+		})
+	}
+
+	if c.metricsHandler != nil {
+		r.Method(http.MethodGet, "/metrics", c.metricsHandler)
+	}
 
 	r.NotFound(h.NotFoundHandler)
 	r.MethodNotAllowed(h.MethodNotAllowedHandler)
@@ -0,0 +1,12 @@
+package entity
+
+type BatchItemResult struct {
+	Index  int          `json:"index"`
+	ID     string       `json:"id,omitempty"`
+	Error  string       `json:"error,omitempty"`
+	Fields []FieldError `json:"fields,omitempty"`
+}
+
+type BatchResponse struct {
+	Results []BatchItemResult `json:"results"`
+}
@@ -0,0 +1,88 @@
+package entity
+
+import (
+	"bytes"
+	"errors"
+	"time"
+)
+
+type TaskCallback struct {
+	URL    string `json:"url"`
+	Secret string `json:"secret,omitempty"`
+}
+
+type CallbackDelivery struct {
+	Status         CallbackStatus `json:"status"`
+	Attempts       int            `json:"attempts,omitempty"`
+	HTTPStatusCode int            `json:"httpStatusCode,omitempty"`
+	LastError      string         `json:"lastError,omitempty"`
+	DeliveredAt    *time.Time     `json:"deliveredAt,omitempty"`
+}
+
+type CallbackStatus int
+
+const (
+	CallbackStatusPending CallbackStatus = iota + 1
+	CallbackStatusDelivered
+	CallbackStatusFailed
+)
+
+var ErrInvalidCallbackStatus = errors.New("invalid callback status")
+
+func (c *CallbackStatus) UnmarshalJSON(i []byte) error {
+	var status CallbackStatus
+
+	i, ok := bytes.CutPrefix(i, []byte("\""))
+	if !ok {
+		return ErrPrefixNotFound
+	}
+
+	i, ok = bytes.CutSuffix(i, []byte("\""))
+	if !ok {
+		return ErrSuffixNotFound
+	}
+
+	switch string(i) {
+	case "pending":
+		status = CallbackStatusPending
+	case "delivered":
+		status = CallbackStatusDelivered
+	case "failed":
+		status = CallbackStatusFailed
+	default:
+		return ErrInvalidCallbackStatus
+	}
+
+	*c = status
+
+	return nil
+}
+
+func (c *CallbackStatus) MarshalJSON() ([]byte, error) {
+	if *c > CallbackStatusFailed || *c < CallbackStatusPending {
+		return nil, ErrInvalidCallbackStatus
+	}
+
+	b := bytes.Buffer{}
+
+	b.WriteByte('"')
+	b.WriteString(c.String())
+	b.WriteByte('"')
+
+	return b.Bytes(), nil
+}
+
+func (c *CallbackStatus) String() string {
+	var status string
+
+	switch *c {
+	case CallbackStatusPending:
+		status = "pending"
+	case CallbackStatusDelivered:
+		status = "delivered"
+	case CallbackStatusFailed:
+		status = "failed"
+	}
+
+	return status
+}
@@ -0,0 +1,39 @@
+package entity
+
+import (
+	"bytes"
+	"time"
+)
+
+type Duration time.Duration
+
+func (d *Duration) UnmarshalJSON(i []byte) error {
+	i, ok := bytes.CutPrefix(i, []byte("\""))
+	if !ok {
+		return ErrPrefixNotFound
+	}
+
+	i, ok = bytes.CutSuffix(i, []byte("\""))
+	if !ok {
+		return ErrSuffixNotFound
+	}
+
+	v, err := time.ParseDuration(string(i))
+	if err != nil {
+		return err
+	}
+
+	*d = Duration(v)
+
+	return nil
+}
+
+func (d *Duration) MarshalJSON() ([]byte, error) {
+	b := bytes.Buffer{}
+
+	b.WriteByte('"')
+	b.WriteString(time.Duration(*d).String())
+	b.WriteByte('"')
+
+	return b.Bytes(), nil
+}
@@ -0,0 +1,48 @@
+package entity_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Mi7teR/aggregator/internal/task/entity"
+)
+
+func TestDuration_UnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		i       []byte
+		want    entity.Duration
+		wantErr bool
+	}{
+		{"unmarshal milliseconds", []byte(`"250ms"`), entity.Duration(250 * time.Millisecond), false},
+		{"unmarshal composite duration", []byte(`"1m30s"`), entity.Duration(90 * time.Second), false},
+		{"unmarshal error prefix not found", []byte(`1s"`), 0, true},
+		{"unmarshal error suffix not found", []byte(`"1s`), 0, true},
+		{"unmarshal error invalid duration", []byte(`"soon"`), 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got entity.Duration
+			if err := got.UnmarshalJSON(tt.i); (err != nil) != tt.wantErr {
+				t.Errorf("UnmarshalJSON() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("UnmarshalJSON() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDuration_MarshalJSON(t *testing.T) {
+	d := entity.Duration(1500 * time.Millisecond)
+
+	got, err := d.MarshalJSON()
+	if err != nil {
+		t.Fatalf("MarshalJSON() error = %v", err)
+	}
+	if want := []byte(`"1.5s"`); !reflect.DeepEqual(got, want) {
+		t.Errorf("MarshalJSON() got = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,142 @@
+package entity_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/Mi7teR/aggregator/internal/task/entity"
+)
+
+func TestTaskBody_UnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		i       []byte
+		want    entity.TaskBody
+		wantErr bool
+	}{
+		{
+			"unmarshal raw string body",
+			[]byte(`"key=value&other=1"`),
+			entity.TaskBody("key=value&other=1"),
+			false,
+		},
+		{
+			"unmarshal json object body",
+			[]byte(`{"key": "value"}`),
+			entity.TaskBody(`{"key": "value"}`),
+			false,
+		},
+		{
+			"unmarshal json array body",
+			[]byte(`[1, 2, 3]`),
+			entity.TaskBody(`[1, 2, 3]`),
+			false,
+		},
+		{
+			"unmarshal null body",
+			[]byte(`null`),
+			nil,
+			false,
+		},
+		{
+			"unmarshal number body error",
+			[]byte(`42`),
+			nil,
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got entity.TaskBody
+			if err := got.UnmarshalJSON(tt.i); (err != nil) != tt.wantErr {
+				t.Errorf("UnmarshalJSON() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("UnmarshalJSON() got = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTaskBody_MarshalJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		t    entity.TaskBody
+		want []byte
+	}{
+		{
+			"marshal raw string body",
+			entity.TaskBody("key=value"),
+			[]byte(`"key=value"`),
+		},
+		{
+			"marshal json object body",
+			entity.TaskBody(`{"key":"value"}`),
+			[]byte(`{"key":"value"}`),
+		},
+		{
+			"marshal invalid json object body as string",
+			entity.TaskBody(`{"key":`),
+			[]byte(`"{\"key\":"`),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.t.MarshalJSON()
+			if err != nil {
+				t.Errorf("MarshalJSON() error = %v", err)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("MarshalJSON() got = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTask_Payload(t *testing.T) {
+	tests := []struct {
+		name    string
+		task    entity.Task
+		want    []byte
+		wantErr bool
+	}{
+		{
+			"payload from body",
+			entity.Task{Body: entity.TaskBody("raw")},
+			[]byte("raw"),
+			false,
+		},
+		{
+			"payload from base64 body",
+			entity.Task{BodyBase64: []byte{0x00, 0xff}},
+			[]byte{0x00, 0xff},
+			false,
+		},
+		{
+			"empty payload",
+			entity.Task{},
+			nil,
+			false,
+		},
+		{
+			"ambiguous payload error",
+			entity.Task{Body: entity.TaskBody("raw"), BodyBase64: []byte("raw")},
+			nil,
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.task.Payload()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Payload() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Payload() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
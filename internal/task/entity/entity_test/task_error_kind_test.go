@@ -0,0 +1,71 @@
+package entity_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/Mi7teR/aggregator/internal/task/entity"
+)
+
+func TestTaskErrorKind_MarshalJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		t       entity.TaskErrorKind
+		want    []byte
+		wantErr bool
+	}{
+		{"marshal kind unknown", entity.ErrorKindUnknown, []byte(`"unknown"`), false},
+		{"marshal kind timeout", entity.ErrorKindTimeout, []byte(`"timeout"`), false},
+		{"marshal kind dns", entity.ErrorKindDNS, []byte(`"dns"`), false},
+		{"marshal kind connection_refused", entity.ErrorKindConnectionRefused, []byte(`"connection_refused"`), false},
+		{"marshal kind tls", entity.ErrorKindTLS, []byte(`"tls"`), false},
+		{"marshal kind invalid_request", entity.ErrorKindInvalidRequest, []byte(`"invalid_request"`), false},
+		{"marshal kind cancelled", entity.ErrorKindCancelled, []byte(`"cancelled"`), false},
+		{
+			"marshal kind destination_not_allowed",
+			entity.ErrorKindDestinationNotAllowed,
+			[]byte(`"destination_not_allowed"`),
+			false,
+		},
+		{"marshal invalid kind error", 0, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.t.MarshalJSON()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("MarshalJSON() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("MarshalJSON() got = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTaskErrorKind_UnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		i       []byte
+		want    entity.TaskErrorKind
+		wantErr bool
+	}{
+		{"unmarshal kind timeout", []byte(`"timeout"`), entity.ErrorKindTimeout, false},
+		{"unmarshal kind connection_refused", []byte(`"connection_refused"`), entity.ErrorKindConnectionRefused, false},
+		{"unmarshal error prefix not found", []byte(`tls"`), 0, true},
+		{"unmarshal error suffix not found", []byte(`"tls`), 0, true},
+		{"unmarshal error invalid kind", []byte(`"other"`), 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got entity.TaskErrorKind
+			if err := got.UnmarshalJSON(tt.i); (err != nil) != tt.wantErr {
+				t.Errorf("UnmarshalJSON() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("UnmarshalJSON() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -38,6 +38,12 @@ func TestTaskResultStatus_MarshalJSON(t *testing.T) {
 			[]byte(`"done"`),
 			false,
 		},
+		{
+			"marshall status cancelled",
+			entity.TaskStatusCancelled,
+			[]byte(`"cancelled"`),
+			false,
+		},
 		{
 			"marshall invalid status error",
 			0,
@@ -85,6 +91,11 @@ func TestTaskResultStatus_String(t *testing.T) {
 			entity.TaskStatusDone,
 			"done",
 		},
+		{
+			"string from task status cancelled",
+			entity.TaskStatusCancelled,
+			"cancelled",
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -137,6 +148,14 @@ func TestTaskResultStatus_UnmarshalJSON(t *testing.T) {
 			},
 			false,
 		},
+		{
+			"unmarshall task status cancelled",
+			entity.TaskStatusCancelled,
+			args{
+				i: []byte(`"cancelled"`),
+			},
+			false,
+		},
 		{
 			"unmarshall error prefix not found",
 			0,
@@ -170,3 +189,24 @@ func TestTaskResultStatus_UnmarshalJSON(t *testing.T) {
 		})
 	}
 }
+
+func TestTaskResultStatus_Terminal(t *testing.T) {
+	tests := []struct {
+		name string
+		t    entity.TaskResultStatus
+		want bool
+	}{
+		{"status new is not terminal", entity.TaskStatusNew, false},
+		{"status in_process is not terminal", entity.TaskStatusInProcess, false},
+		{"status error is terminal", entity.TaskStatusError, true},
+		{"status done is terminal", entity.TaskStatusDone, true},
+		{"status cancelled is terminal", entity.TaskStatusCancelled, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.t.Terminal(); got != tt.want {
+				t.Errorf("Terminal() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
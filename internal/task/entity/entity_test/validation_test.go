@@ -0,0 +1,94 @@
+package entity_test
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Mi7teR/aggregator/internal/task/entity"
+)
+
+func TestTask_Validate(t *testing.T) {
+	tests := []struct {
+		name       string
+		task       entity.Task
+		wantFields []string
+	}{
+		{
+			"valid task",
+			entity.Task{Method: entity.MethodGet, URL: "https://example.com/path", Headers: map[string]string{"Accept": "*/*"}},
+			nil,
+		},
+		{"empty url", entity.Task{URL: ""}, []string{"url"}},
+		{"relative url", entity.Task{URL: "/path"}, []string{"url"}},
+		{"unsupported scheme", entity.Task{URL: "ftp://example.com/file"}, []string{"url"}},
+		{"missing host", entity.Task{URL: "http:///path"}, []string{"url"}},
+		{"url too long", entity.Task{URL: "http://example.com/" + strings.Repeat("a", entity.MaxURLLength)}, []string{"url"}},
+		{"invalid method", entity.Task{Method: entity.TaskMethod(100), URL: "http://example.com"}, []string{"method"}},
+		{
+			"invalid header name",
+			entity.Task{URL: "http://example.com", Headers: map[string]string{"Bad Header": "value"}},
+			[]string{"headers[Bad Header]"},
+		},
+		{
+			"invalid header value",
+			entity.Task{URL: "http://example.com", Headers: map[string]string{"X-Test": "a\r\nb"}},
+			[]string{"headers[X-Test]"},
+		},
+		{
+			"ambiguous body",
+			entity.Task{URL: "http://example.com", Body: entity.TaskBody("a"), BodyBase64: []byte("a")},
+			[]string{"body"},
+		},
+		{
+			"invalid retry",
+			entity.Task{URL: "http://example.com", Retry: &entity.TaskRetry{MaxAttempts: -1, RetryStatusCodes: []int{42}}},
+			[]string{"retry.maxAttempts", "retry.retryStatusCodes"},
+		},
+		{
+			"negative timeout",
+			entity.Task{URL: "http://example.com", Timeouts: &entity.TaskTimeouts{Connect: -1}},
+			[]string{"timeouts.connect"},
+		},
+		{
+			"too many redirect hops",
+			entity.Task{URL: "http://example.com", Redirect: &entity.TaskRedirectPolicy{MaxHops: entity.MaxRedirectHops + 1}},
+			[]string{"redirect.maxHops"},
+		},
+		{
+			"invalid callback url",
+			entity.Task{URL: "http://example.com", Callback: &entity.TaskCallback{URL: "callback"}},
+			[]string{"callback.url"},
+		},
+		{
+			"multiple errors",
+			entity.Task{URL: "", Headers: map[string]string{"": "value"}},
+			[]string{"url", "headers[]"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.task.Validate()
+			if tt.wantFields == nil {
+				if err != nil {
+					t.Errorf("Validate() error = %v, want nil", err)
+				}
+				return
+			}
+
+			var validationErr *entity.ValidationError
+			if !errors.As(err, &validationErr) {
+				t.Fatalf("Validate() error = %v, want *entity.ValidationError", err)
+			}
+
+			got := make([]string, 0, len(validationErr.Fields))
+			for _, f := range validationErr.Fields {
+				got = append(got, f.Field)
+			}
+			if !reflect.DeepEqual(got, tt.wantFields) {
+				t.Errorf("Validate() fields = %v, want %v", got, tt.wantFields)
+			}
+		})
+	}
+}
@@ -1,5 +1,6 @@
 package entity
 
 type ErrorResponse struct {
-	Error string `json:"error"`
+	Error  string       `json:"error"`
+	Fields []FieldError `json:"fields,omitempty"`
 }
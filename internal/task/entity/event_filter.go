@@ -0,0 +1,33 @@
+package entity
+
+type EventFilter struct {
+	IDs      []string
+	Statuses []TaskResultStatus
+	Owner    string
+}
+
+func (f *EventFilter) Match(res *TaskResult) bool {
+	if len(f.IDs) > 0 && !contains(f.IDs, res.ID) {
+		return false
+	}
+
+	if len(f.Statuses) > 0 && !contains(f.Statuses, res.Status) {
+		return false
+	}
+
+	if f.Owner != "" && res.Owner != f.Owner {
+		return false
+	}
+
+	return true
+}
+
+func contains[T comparable](values []T, v T) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+
+	return false
+}
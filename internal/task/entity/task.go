@@ -1,7 +1,27 @@
 package entity
 
 type Task struct {
-	Method  TaskMethod        `json:"method"`
-	URL     string            `json:"url"`
-	Headers map[string]string `json:"headers"`
+	Method      TaskMethod          `json:"method"`
+	URL         string              `json:"url"`
+	Headers     map[string]string   `json:"headers"`
+	Body        TaskBody            `json:"body,omitempty"`
+	BodyBase64  []byte              `json:"bodyBase64,omitempty"`
+	CaptureBody bool                `json:"captureBody,omitempty"`
+	Retry       *TaskRetry          `json:"retry,omitempty"`
+	Timeouts    *TaskTimeouts       `json:"timeouts,omitempty"`
+	Redirect    *TaskRedirectPolicy `json:"redirect,omitempty"`
+	Callback    *TaskCallback       `json:"callback,omitempty"`
+	Owner       string              `json:"-"`
+}
+
+func (t *Task) Payload() ([]byte, error) {
+	if len(t.Body) > 0 && len(t.BodyBase64) > 0 {
+		return nil, ErrAmbiguousBody
+	}
+
+	if len(t.BodyBase64) > 0 {
+		return t.BodyBase64, nil
+	}
+
+	return t.Body, nil
 }
@@ -0,0 +1,56 @@
+package entity
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+)
+
+type TaskBody []byte
+
+var (
+	ErrInvalidBody   = errors.New("invalid body: expected string, object or array")
+	ErrAmbiguousBody = errors.New("only one of body and bodyBase64 can be set")
+)
+
+func (t *TaskBody) UnmarshalJSON(i []byte) error {
+	i = bytes.TrimSpace(i)
+	if len(i) == 0 {
+		return ErrInvalidBody
+	}
+
+	switch i[0] {
+	case 'n':
+		*t = nil
+	case '"':
+		var s string
+		if err := json.Unmarshal(i, &s); err != nil {
+			return err
+		}
+
+		*t = TaskBody(s)
+	case '{', '[':
+		*t = append(TaskBody(nil), i...)
+	default:
+		return ErrInvalidBody
+	}
+
+	return nil
+}
+
+func (t *TaskBody) MarshalJSON() ([]byte, error) {
+	if t.isJSON() {
+		return append([]byte(nil), *t...), nil
+	}
+
+	return json.Marshal(string(*t))
+}
+
+func (t *TaskBody) isJSON() bool {
+	b := bytes.TrimSpace(*t)
+	if len(b) == 0 || (b[0] != '{' && b[0] != '[') {
+		return false
+	}
+
+	return json.Valid(b)
+}
@@ -0,0 +1,99 @@
+package entity
+
+import (
+	"bytes"
+	"errors"
+)
+
+type TaskErrorKind int
+
+const (
+	ErrorKindUnknown TaskErrorKind = iota + 1
+	ErrorKindTimeout
+	ErrorKindDNS
+	ErrorKindConnectionRefused
+	ErrorKindTLS
+	ErrorKindInvalidRequest
+	ErrorKindCancelled
+	ErrorKindDestinationNotAllowed
+)
+
+var ErrInvalidErrorKind = errors.New("invalid error kind")
+
+func (t *TaskErrorKind) UnmarshalJSON(i []byte) error {
+	var kind TaskErrorKind
+
+	i, ok := bytes.CutPrefix(i, []byte("\""))
+	if !ok {
+		return ErrPrefixNotFound
+	}
+
+	i, ok = bytes.CutSuffix(i, []byte("\""))
+	if !ok {
+		return ErrSuffixNotFound
+	}
+
+	switch string(i) {
+	case "unknown":
+		kind = ErrorKindUnknown
+	case "timeout":
+		kind = ErrorKindTimeout
+	case "dns":
+		kind = ErrorKindDNS
+	case "connection_refused":
+		kind = ErrorKindConnectionRefused
+	case "tls":
+		kind = ErrorKindTLS
+	case "invalid_request":
+		kind = ErrorKindInvalidRequest
+	case "cancelled":
+		kind = ErrorKindCancelled
+	case "destination_not_allowed":
+		kind = ErrorKindDestinationNotAllowed
+	default:
+		return ErrInvalidErrorKind
+	}
+
+	*t = kind
+
+	return nil
+}
+
+func (t *TaskErrorKind) MarshalJSON() ([]byte, error) {
+	if *t > ErrorKindDestinationNotAllowed || *t < ErrorKindUnknown {
+		return nil, ErrInvalidErrorKind
+	}
+
+	b := bytes.Buffer{}
+
+	b.WriteByte('"')
+	b.WriteString(t.String())
+	b.WriteByte('"')
+
+	return b.Bytes(), nil
+}
+
+func (t *TaskErrorKind) String() string {
+	var kind string
+
+	switch *t {
+	case ErrorKindUnknown:
+		kind = "unknown"
+	case ErrorKindTimeout:
+		kind = "timeout"
+	case ErrorKindDNS:
+		kind = "dns"
+	case ErrorKindConnectionRefused:
+		kind = "connection_refused"
+	case ErrorKindTLS:
+		kind = "tls"
+	case ErrorKindInvalidRequest:
+		kind = "invalid_request"
+	case ErrorKindCancelled:
+		kind = "cancelled"
+	case ErrorKindDestinationNotAllowed:
+		kind = "destination_not_allowed"
+	}
+
+	return kind
+}
@@ -0,0 +1,19 @@
+package entity
+
+import "time"
+
+type TaskFilter struct {
+	Statuses      []TaskResultStatus
+	Methods       []TaskMethod
+	Host          string
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+	Cursor        string
+	Limit         int
+	Owner         string
+}
+
+type TaskList struct {
+	Items      []TaskResult `json:"items"`
+	NextCursor string       `json:"nextCursor,omitempty"`
+}
@@ -27,8 +27,6 @@ var (
 )
 
 func (t *TaskMethod) UnmarshalJSON(i []byte) error {
-	var method TaskMethod
-
 	i, ok := bytes.CutPrefix(i, []byte("\""))
 	if !ok {
 		return ErrPrefixNotFound
@@ -39,7 +37,20 @@ func (t *TaskMethod) UnmarshalJSON(i []byte) error {
 		return ErrSuffixNotFound
 	}
 
-	switch string(i) {
+	method, err := ParseTaskMethod(string(i))
+	if err != nil {
+		return err
+	}
+
+	*t = method
+
+	return nil
+}
+
+func ParseTaskMethod(s string) (TaskMethod, error) {
+	var method TaskMethod
+
+	switch s {
 	case http.MethodGet:
 		method = MethodGet
 	case http.MethodHead:
@@ -59,12 +70,10 @@ func (t *TaskMethod) UnmarshalJSON(i []byte) error {
 	case http.MethodTrace:
 		method = MethodTrace
 	default:
-		return ErrInvalidMethod
+		return 0, ErrInvalidMethod
 	}
 
-	*t = method
-
-	return nil
+	return method, nil
 }
 
 func (t *TaskMethod) MarshalJSON() ([]byte, error) {
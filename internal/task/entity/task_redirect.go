@@ -0,0 +1,13 @@
+package entity
+
+type TaskRedirectPolicy struct {
+	NoFollow     bool `json:"noFollow,omitempty"`
+	MaxHops      int  `json:"maxHops,omitempty"`
+	SameHostOnly bool `json:"sameHostOnly,omitempty"`
+}
+
+type TaskRedirect struct {
+	URL        string `json:"url"`
+	StatusCode int    `json:"statusCode"`
+	Location   string `json:"location,omitempty"`
+}
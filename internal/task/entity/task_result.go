@@ -1,11 +1,28 @@
 package entity
 
-import "net/http"
+import (
+	"net/http"
+	"time"
+)
 
 type TaskResult struct {
-	ID             string           `json:"id"`
-	Status         TaskResultStatus `json:"status,omitempty"`
-	HTTPStatusCode int              `json:"httpStatusCode,omitempty"`
-	Headers        http.Header      `json:"headers,omitempty"`
-	Length         int64            `json:"length,omitempty"`
+	ID             string            `json:"id"`
+	Status         TaskResultStatus  `json:"status,omitempty"`
+	CreatedAt      *time.Time        `json:"createdAt,omitempty"`
+	StartedAt      *time.Time        `json:"startedAt,omitempty"`
+	FinishedAt     *time.Time        `json:"finishedAt,omitempty"`
+	HTTPStatusCode int               `json:"httpStatusCode,omitempty"`
+	Headers        http.Header       `json:"headers,omitempty"`
+	Length         int64             `json:"length,omitempty"`
+	Error          string            `json:"error,omitempty"`
+	ErrorKind      TaskErrorKind     `json:"errorKind,omitempty"`
+	AttemptCount   int               `json:"attemptCount,omitempty"`
+	Attempts       []TaskAttempt     `json:"attempts,omitempty"`
+	Timing         *TaskTiming       `json:"timing,omitempty"`
+	Redirects      []TaskRedirect    `json:"redirects,omitempty"`
+	Callback       *CallbackDelivery `json:"callback,omitempty"`
+	BodyCaptured   bool              `json:"bodyCaptured,omitempty"`
+	BodyTruncated  bool              `json:"bodyTruncated,omitempty"`
+	Body           []byte            `json:"-"`
+	Owner          string            `json:"-"`
 }
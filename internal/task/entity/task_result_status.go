@@ -12,13 +12,12 @@ const (
 	TaskStatusInProcess
 	TaskStatusError
 	TaskStatusDone
+	TaskStatusCancelled
 )
 
 var ErrInvalidStatus = errors.New("invalid status")
 
 func (t *TaskResultStatus) UnmarshalJSON(i []byte) error {
-	var status TaskResultStatus
-
 	i, ok := bytes.CutPrefix(i, []byte("\""))
 	if !ok {
 		return ErrPrefixNotFound
@@ -29,7 +28,20 @@ func (t *TaskResultStatus) UnmarshalJSON(i []byte) error {
 		return ErrSuffixNotFound
 	}
 
-	switch string(i) {
+	status, err := ParseTaskResultStatus(string(i))
+	if err != nil {
+		return err
+	}
+
+	*t = status
+
+	return nil
+}
+
+func ParseTaskResultStatus(s string) (TaskResultStatus, error) {
+	var status TaskResultStatus
+
+	switch s {
 	case "new":
 		status = TaskStatusNew
 	case "in_process":
@@ -38,17 +50,17 @@ func (t *TaskResultStatus) UnmarshalJSON(i []byte) error {
 		status = TaskStatusError
 	case "done":
 		status = TaskStatusDone
+	case "cancelled":
+		status = TaskStatusCancelled
 	default:
-		return ErrInvalidStatus
+		return 0, ErrInvalidStatus
 	}
 
-	*t = status
-
-	return nil
+	return status, nil
 }
 
 func (t *TaskResultStatus) MarshalJSON() ([]byte, error) {
-	if *t > TaskStatusDone || *t < TaskStatusNew {
+	if *t > TaskStatusCancelled || *t < TaskStatusNew {
 		return nil, ErrInvalidStatus
 	}
 
@@ -73,7 +85,13 @@ func (t *TaskResultStatus) String() string {
 		status = "error"
 	case TaskStatusDone:
 		status = "done"
+	case TaskStatusCancelled:
+		status = "cancelled"
 	}
 
 	return status
 }
+
+func (t *TaskResultStatus) Terminal() bool {
+	return *t == TaskStatusError || *t == TaskStatusDone || *t == TaskStatusCancelled
+}
@@ -0,0 +1,17 @@
+package entity
+
+type TaskRetry struct {
+	MaxAttempts       int      `json:"maxAttempts"`
+	BackoffBase       Duration `json:"backoffBase,omitempty"`
+	BackoffMax        Duration `json:"backoffMax,omitempty"`
+	Jitter            bool     `json:"jitter,omitempty"`
+	RetryStatusCodes  []int    `json:"retryStatusCodes,omitempty"`
+	RespectRetryAfter bool     `json:"respectRetryAfter,omitempty"`
+}
+
+type TaskAttempt struct {
+	HTTPStatusCode int           `json:"httpStatusCode,omitempty"`
+	Error          string        `json:"error,omitempty"`
+	ErrorKind      TaskErrorKind `json:"errorKind,omitempty"`
+	Backoff        Duration      `json:"backoff,omitempty"`
+}
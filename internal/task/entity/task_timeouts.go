@@ -0,0 +1,8 @@
+package entity
+
+type TaskTimeouts struct {
+	Total          Duration `json:"total,omitempty"`
+	Connect        Duration `json:"connect,omitempty"`
+	TLSHandshake   Duration `json:"tlsHandshake,omitempty"`
+	ResponseHeader Duration `json:"responseHeader,omitempty"`
+}
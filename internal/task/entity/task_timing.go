@@ -0,0 +1,12 @@
+package entity
+
+// TaskTiming breaks down the final attempt. DNS, Connect, TLSHandshake and
+// TimeToFirstByte describe its last request when redirects were followed,
+// Total covers the whole attempt.
+type TaskTiming struct {
+	DNS             Duration `json:"dns,omitempty"`
+	Connect         Duration `json:"connect,omitempty"`
+	TLSHandshake    Duration `json:"tlsHandshake,omitempty"`
+	TimeToFirstByte Duration `json:"ttfb,omitempty"`
+	Total           Duration `json:"total,omitempty"`
+}
@@ -0,0 +1,22 @@
+package entity
+
+type TransportStats struct {
+	OpenConns   int64             `json:"openConns"`
+	ConnsOpened int64             `json:"connsOpened"`
+	ConnsClosed int64             `json:"connsClosed"`
+	Requests    int64             `json:"requests"`
+	ReusedConns int64             `json:"reusedConns"`
+	Settings    TransportSettings `json:"settings"`
+}
+
+type TransportSettings struct {
+	MaxIdleConns        int      `json:"maxIdleConns"`
+	MaxIdleConnsPerHost int      `json:"maxIdleConnsPerHost"`
+	MaxConnsPerHost     int      `json:"maxConnsPerHost,omitempty"`
+	IdleConnTimeout     Duration `json:"idleConnTimeout"`
+	KeepAlive           Duration `json:"keepAlive"`
+	DisableKeepAlives   bool     `json:"disableKeepAlives,omitempty"`
+	HTTP2               bool     `json:"http2"`
+	TLSMinVersion       string   `json:"tlsMinVersion,omitempty"`
+	Proxy               string   `json:"proxy,omitempty"`
+}
@@ -0,0 +1,242 @@
+package entity
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+const (
+	MaxURLLength         = 8 << 10
+	MaxHeaders           = 100
+	MaxHeaderNameLength  = 256
+	MaxHeaderValueLength = 8 << 10
+	MaxPayloadSize       = 10 << 20
+	MaxRedirectHops      = 10
+
+	minStatusCode = 100
+	maxStatusCode = 599
+)
+
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+	Err     error  `json:"-"`
+}
+
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Field, e.Message)
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+type ValidationError struct {
+	Fields []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, 0, len(e.Fields))
+	for i := range e.Fields {
+		msgs = append(msgs, e.Fields[i].Error())
+	}
+
+	return "invalid task: " + strings.Join(msgs, "; ")
+}
+
+func (e *ValidationError) Unwrap() []error {
+	errs := make([]error, 0, len(e.Fields))
+	for i := range e.Fields {
+		errs = append(errs, &e.Fields[i])
+	}
+
+	return errs
+}
+
+// Validate checks the task before it is stored, so malformed tasks are
+// rejected up front instead of failing inside the executor.
+func (t *Task) Validate() error {
+	v := &ValidationError{}
+
+	if t.Method < MethodGet || t.Method > MethodTrace {
+		v.add("method", "is not a valid http method")
+	}
+
+	if msg := validateURL(t.URL); msg != "" {
+		v.add("url", msg)
+	}
+
+	if len(t.Headers) > MaxHeaders {
+		v.add("headers", fmt.Sprintf("must not contain more than %d headers", MaxHeaders))
+	}
+
+	names := make([]string, 0, len(t.Headers))
+	for name := range t.Headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		value := t.Headers[name]
+		if msg := validateHeaderName(name); msg != "" {
+			v.add(fmt.Sprintf("headers[%s]", name), msg)
+			continue
+		}
+
+		if msg := validateHeaderValue(value); msg != "" {
+			v.add(fmt.Sprintf("headers[%s]", name), msg)
+		}
+	}
+
+	if payload, err := t.Payload(); err != nil {
+		v.add("body", err.Error())
+	} else if len(payload) > MaxPayloadSize {
+		v.add("body", fmt.Sprintf("must not exceed %d bytes", MaxPayloadSize))
+	}
+
+	t.validateRetry(v)
+	t.validateTimeouts(v)
+
+	if t.Redirect != nil {
+		if t.Redirect.MaxHops < 0 {
+			v.add("redirect.maxHops", "must not be negative")
+		} else if t.Redirect.MaxHops > MaxRedirectHops {
+			v.add("redirect.maxHops", fmt.Sprintf("must not exceed %d", MaxRedirectHops))
+		}
+	}
+
+	if t.Callback != nil {
+		if msg := validateURL(t.Callback.URL); msg != "" {
+			v.add("callback.url", msg)
+		}
+	}
+
+	if len(v.Fields) > 0 {
+		return v
+	}
+
+	return nil
+}
+
+func (t *Task) validateRetry(v *ValidationError) {
+	if t.Retry == nil {
+		return
+	}
+
+	if t.Retry.MaxAttempts < 0 {
+		v.add("retry.maxAttempts", "must not be negative")
+	}
+
+	if t.Retry.BackoffBase < 0 {
+		v.add("retry.backoffBase", "must not be negative")
+	}
+
+	if t.Retry.BackoffMax < 0 {
+		v.add("retry.backoffMax", "must not be negative")
+	}
+
+	for _, code := range t.Retry.RetryStatusCodes {
+		if code < minStatusCode || code > maxStatusCode {
+			v.add("retry.retryStatusCodes", fmt.Sprintf("invalid status code %d", code))
+		}
+	}
+}
+
+func (t *Task) validateTimeouts(v *ValidationError) {
+	if t.Timeouts == nil {
+		return
+	}
+
+	timeouts := []struct {
+		field string
+		d     Duration
+	}{
+		{"timeouts.total", t.Timeouts.Total},
+		{"timeouts.connect", t.Timeouts.Connect},
+		{"timeouts.tlsHandshake", t.Timeouts.TLSHandshake},
+		{"timeouts.responseHeader", t.Timeouts.ResponseHeader},
+	}
+
+	for _, timeout := range timeouts {
+		if timeout.d < 0 {
+			v.add(timeout.field, "must not be negative")
+		}
+	}
+}
+
+func (v *ValidationError) add(field, msg string) {
+	v.Fields = append(v.Fields, FieldError{Field: field, Message: msg})
+}
+
+func validateURL(raw string) string {
+	if raw == "" {
+		return "is required"
+	}
+
+	if len(raw) > MaxURLLength {
+		return fmt.Sprintf("must not exceed %d characters", MaxURLLength)
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "is not a valid url"
+	}
+
+	if !u.IsAbs() {
+		return "must be an absolute url"
+	}
+
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Sprintf("unsupported scheme %q, expected http or https", u.Scheme)
+	}
+
+	if u.Host == "" {
+		return "must contain a host"
+	}
+
+	return ""
+}
+
+func validateHeaderName(name string) string {
+	if name == "" {
+		return "header name must not be empty"
+	}
+
+	if len(name) > MaxHeaderNameLength {
+		return fmt.Sprintf("header name must not exceed %d characters", MaxHeaderNameLength)
+	}
+
+	for i := 0; i < len(name); i++ {
+		if !isTokenChar(name[i]) {
+			return fmt.Sprintf("header name contains invalid character %q", name[i])
+		}
+	}
+
+	return ""
+}
+
+func validateHeaderValue(value string) string {
+	if len(value) > MaxHeaderValueLength {
+		return fmt.Sprintf("header value must not exceed %d characters", MaxHeaderValueLength)
+	}
+
+	for i := 0; i < len(value); i++ {
+		if c := value[i]; (c < ' ' && c != '\t') || c == 0x7f {
+			return fmt.Sprintf("header value contains invalid character %q", c)
+		}
+	}
+
+	return ""
+}
+
+// isTokenChar reports whether c may appear in a header name (RFC 7230).
+func isTokenChar(c byte) bool {
+	switch {
+	case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		return true
+	default:
+		return strings.IndexByte("!#$%&'*+-.^_`|~", c) >= 0
+	}
+}
@@ -0,0 +1,182 @@
+package repository
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Mi7teR/aggregator/internal/task/entity"
+)
+
+const (
+	DefaultListLimit = 50
+	MaxListLimit     = 500
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// taskSummary is the part of a task List filters on. Records keep it rather
+// than the task itself, so payloads, headers and callback secrets are not
+// retained with the result.
+type taskSummary struct {
+	Method entity.TaskMethod `json:"method"`
+	Host   string            `json:"host,omitempty"`
+}
+
+func summarize(task *entity.Task) taskSummary {
+	sum := taskSummary{Method: task.Method}
+	if u, err := url.Parse(task.URL); err == nil {
+		sum.Host = u.Hostname()
+	}
+
+	return sum
+}
+
+func matchFilter(f *entity.TaskFilter, task *taskSummary, res *entity.TaskResult) bool {
+	if len(f.Statuses) > 0 && !containsStatus(f.Statuses, res.Status) {
+		return false
+	}
+
+	if len(f.Methods) > 0 && !containsMethod(f.Methods, task.Method) {
+		return false
+	}
+
+	if f.Owner != "" && res.Owner != f.Owner {
+		return false
+	}
+
+	if f.Host != "" && !strings.EqualFold(task.Host, f.Host) {
+		return false
+	}
+
+	if res.CreatedAt == nil {
+		return f.CreatedAfter.IsZero() && f.CreatedBefore.IsZero()
+	}
+
+	if !f.CreatedAfter.IsZero() && res.CreatedAt.Before(f.CreatedAfter) {
+		return false
+	}
+
+	if !f.CreatedBefore.IsZero() && !res.CreatedAt.Before(f.CreatedBefore) {
+		return false
+	}
+
+	return true
+}
+
+func containsStatus(statuses []entity.TaskResultStatus, status entity.TaskResultStatus) bool {
+	for _, s := range statuses {
+		if s == status {
+			return true
+		}
+	}
+
+	return false
+}
+
+func containsMethod(methods []entity.TaskMethod, method entity.TaskMethod) bool {
+	for _, m := range methods {
+		if m == method {
+			return true
+		}
+	}
+
+	return false
+}
+
+// paginate orders records by creation time and id and returns the page that
+// follows the filter cursor.
+func paginate(f *entity.TaskFilter, records []entity.TaskResult) (*entity.TaskList, error) {
+	sort.Slice(records, func(i, j int) bool {
+		return recordLess(&records[i], &records[j])
+	})
+
+	start := 0
+	if f.Cursor != "" {
+		after, err := decodeCursor(f.Cursor)
+		if err != nil {
+			return nil, err
+		}
+
+		start = sort.Search(len(records), func(i int) bool {
+			return recordLess(after, &records[i])
+		})
+	}
+
+	limit := f.Limit
+	if limit <= 0 {
+		limit = DefaultListLimit
+	}
+	if limit > MaxListLimit {
+		limit = MaxListLimit
+	}
+
+	list := &entity.TaskList{Items: make([]entity.TaskResult, 0, limit)}
+	for i := start; i < len(records) && len(list.Items) < limit; i++ {
+		list.Items = append(list.Items, records[i])
+	}
+
+	if end := start + len(list.Items); end < len(records) {
+		list.NextCursor = encodeCursor(&records[end-1])
+	}
+
+	return list, nil
+}
+
+func recordLess(a, b *entity.TaskResult) bool {
+	at, bt := createdAt(a), createdAt(b)
+	if !at.Equal(bt) {
+		return at.Before(bt)
+	}
+
+	return a.ID < b.ID
+}
+
+func createdAt(res *entity.TaskResult) time.Time {
+	if res.CreatedAt == nil {
+		return time.Time{}
+	}
+
+	return *res.CreatedAt
+}
+
+func encodeCursor(res *entity.TaskResult) string {
+	var nanos int64
+	if res.CreatedAt != nil {
+		nanos = res.CreatedAt.UnixNano()
+	}
+
+	raw := fmt.Sprintf("%d:%s", nanos, res.ID)
+
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeCursor(cursor string) (*entity.TaskResult, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	nanos, id, ok := strings.Cut(string(raw), ":")
+	if !ok {
+		return nil, ErrInvalidCursor
+	}
+
+	n, err := strconv.ParseInt(nanos, 10, 64)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	after := &entity.TaskResult{ID: id}
+	if n != 0 {
+		created := time.Unix(0, n).UTC()
+		after.CreatedAt = &created
+	}
+
+	return after, nil
+}
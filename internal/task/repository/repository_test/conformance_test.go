@@ -0,0 +1,471 @@
+package repository_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Mi7teR/aggregator/internal/task/entity"
+	"github.com/Mi7teR/aggregator/internal/task/repository"
+	"github.com/Mi7teR/aggregator/internal/task/service"
+	"github.com/google/uuid"
+)
+
+func TestTaskInMemoryRepository_Conformance(t *testing.T) {
+	testRepository(t, func(t *testing.T) service.Repository {
+		return repository.NewTaskInMemoryRepository()
+	})
+}
+
+func TestTaskBoltRepository_Conformance(t *testing.T) {
+	testRepository(t, func(t *testing.T) service.Repository {
+		repo, err := repository.NewTaskBoltRepository(filepath.Join(t.TempDir(), "tasks.db"))
+		if err != nil {
+			t.Fatalf("NewTaskBoltRepository() error = %v", err)
+		}
+		t.Cleanup(func() { _ = repo.Close() })
+
+		return repo
+	})
+}
+
+func TestTaskBoltRepository_Reopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tasks.db")
+
+	repo, err := repository.NewTaskBoltRepository(path)
+	if err != nil {
+		t.Fatalf("NewTaskBoltRepository() error = %v", err)
+	}
+
+	id, err := repo.Create(context.Background(), &entity.Task{})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	doneID, err := repo.Create(context.Background(), &entity.Task{})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	err = repo.Update(context.Background(), &entity.TaskResult{ID: doneID, Status: entity.TaskStatusDone})
+	if err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+
+	notifyingID, err := repo.Create(context.Background(), &entity.Task{})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	err = repo.Update(context.Background(), &entity.TaskResult{
+		ID:       notifyingID,
+		Status:   entity.TaskStatusDone,
+		Callback: &entity.CallbackDelivery{Status: entity.CallbackStatusPending},
+	})
+	if err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+
+	if err = repo.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	repo, err = repository.NewTaskBoltRepository(path)
+	if err != nil {
+		t.Fatalf("NewTaskBoltRepository() error = %v", err)
+	}
+	defer repo.Close()
+
+	res, err := repo.GetByID(context.Background(), id)
+	if err != nil {
+		t.Fatalf("GetByID() after reopen error = %v", err)
+	}
+	if res.Status != entity.TaskStatusError || res.ErrorKind != entity.ErrorKindCancelled || res.FinishedAt == nil {
+		t.Errorf("GetByID() after reopen got %+v, want interrupted task to be cancelled", res)
+	}
+
+	res, err = repo.GetByID(context.Background(), doneID)
+	if err != nil {
+		t.Fatalf("GetByID() after reopen error = %v", err)
+	}
+	if res.Status != entity.TaskStatusDone {
+		t.Errorf("GetByID() after reopen status = %v, want finished task untouched", res.Status)
+	}
+
+	res, err = repo.GetByID(context.Background(), notifyingID)
+	if err != nil {
+		t.Fatalf("GetByID() after reopen error = %v", err)
+	}
+	if res.Status != entity.TaskStatusDone || res.Callback == nil ||
+		res.Callback.Status != entity.CallbackStatusFailed || res.Callback.LastError == "" {
+		t.Errorf("GetByID() after reopen got %+v, want pending callback delivery to be failed", res)
+	}
+
+	evicted, err := repo.Evict(context.Background(), time.Now().Add(time.Minute), 0)
+	if err != nil {
+		t.Fatalf("Evict() error = %v", err)
+	}
+	if evicted != 3 {
+		t.Errorf("Evict() after reopen = %d, want interrupted tasks to be evictable", evicted)
+	}
+}
+
+func TestTaskBoltRepository_KeepsNoTaskSecrets(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tasks.db")
+
+	repo, err := repository.NewTaskBoltRepository(path)
+	if err != nil {
+		t.Fatalf("NewTaskBoltRepository() error = %v", err)
+	}
+
+	_, err = repo.Create(context.Background(), &entity.Task{
+		Method:     entity.MethodPost,
+		URL:        "https://api.example.com/orders",
+		Headers:    map[string]string{"Authorization": "Bearer header-secret"},
+		BodyBase64: []byte("payload-secret"),
+		Callback:   &entity.TaskCallback{URL: "https://hooks.example.com", Secret: "callback-secret"},
+	})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	list, err := repo.List(context.Background(), &entity.TaskFilter{
+		Methods: []entity.TaskMethod{entity.MethodPost},
+		Host:    "API.example.com",
+	})
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(list.Items) != 1 {
+		t.Errorf("List() by method and host got %d items, want 1", len(list.Items))
+	}
+
+	if err = repo.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	for _, secret := range []string{"header-secret", "payload-secret", "callback-secret", "/orders"} {
+		if bytes.Contains(data, []byte(secret)) {
+			t.Errorf("bolt file contains %q, want only the method and host of the task", secret)
+		}
+	}
+}
+
+func testRepository(t *testing.T, newRepo func(t *testing.T) service.Repository) {
+	t.Run("create returns new task result", func(t *testing.T) {
+		repo := newRepo(t)
+
+		id, err := repo.Create(context.Background(), &entity.Task{})
+		if err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+		if _, err = uuid.Parse(id); err != nil {
+			t.Errorf("Create() returned invalid uuid %q", id)
+		}
+
+		got, err := repo.GetByID(context.Background(), id)
+		if err != nil {
+			t.Fatalf("GetByID() error = %v", err)
+		}
+
+		if got.CreatedAt == nil || time.Since(*got.CreatedAt) > time.Minute {
+			t.Errorf("GetByID() created at = %v, want current time", got.CreatedAt)
+		}
+
+		want := &entity.TaskResult{ID: id, Status: entity.TaskStatusNew, CreatedAt: got.CreatedAt}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("GetByID() got = %v, want %v", got, want)
+		}
+	})
+
+	t.Run("create returns unique ids", func(t *testing.T) {
+		repo := newRepo(t)
+
+		first, _ := repo.Create(context.Background(), &entity.Task{})
+		second, _ := repo.Create(context.Background(), &entity.Task{})
+		if first == second {
+			t.Errorf("Create() returned duplicate id %q", first)
+		}
+	})
+
+	t.Run("get non-existent task result", func(t *testing.T) {
+		repo := newRepo(t)
+
+		if _, err := repo.GetByID(context.Background(), uuid.New().String()); !errors.Is(err, repository.ErrNotFound) {
+			t.Errorf("GetByID() error = %v, want %v", err, repository.ErrNotFound)
+		}
+	})
+
+	t.Run("update existent task result", func(t *testing.T) {
+		repo := newRepo(t)
+
+		id, _ := repo.Create(context.Background(), &entity.Task{})
+		created, _ := repo.GetByID(context.Background(), id)
+		want := &entity.TaskResult{
+			ID:             id,
+			Status:         entity.TaskStatusDone,
+			HTTPStatusCode: http.StatusOK,
+			Headers:        http.Header{"Content-Type": {"text/plain"}},
+			Length:         4,
+			BodyCaptured:   true,
+			Body:           []byte("body"),
+		}
+
+		if err := repo.Update(context.Background(), want); err != nil {
+			t.Fatalf("Update() error = %v", err)
+		}
+
+		want.CreatedAt = created.CreatedAt
+
+		got, err := repo.GetByID(context.Background(), id)
+		if err != nil {
+			t.Fatalf("GetByID() error = %v", err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("GetByID() got = %v, want %v", got, want)
+		}
+	})
+
+	t.Run("list task results", func(t *testing.T) {
+		repo := newRepo(t)
+
+		tasks := []*entity.Task{
+			{Method: entity.MethodGet, URL: "https://example.com/a", Owner: "alice"},
+			{Method: entity.MethodPost, URL: "https://example.com/b"},
+			{Method: entity.MethodGet, URL: "http://other.org/c", Owner: "alice"},
+			{Method: entity.MethodPut, URL: "https://EXAMPLE.com:8443/d"},
+			{Method: entity.MethodGet, URL: "https://example.com/e"},
+		}
+
+		ids := make([]string, 0, len(tasks))
+		for _, task := range tasks {
+			id, err := repo.Create(context.Background(), task)
+			if err != nil {
+				t.Fatalf("Create() error = %v", err)
+			}
+			ids = append(ids, id)
+		}
+
+		for _, id := range []string{ids[1], ids[3]} {
+			if err := repo.Update(context.Background(), &entity.TaskResult{ID: id, Status: entity.TaskStatusDone}); err != nil {
+				t.Fatalf("Update() error = %v", err)
+			}
+		}
+
+		first, _ := repo.GetByID(context.Background(), ids[0])
+		last, _ := repo.GetByID(context.Background(), ids[len(ids)-1])
+
+		tests := []struct {
+			name   string
+			filter entity.TaskFilter
+			want   []string
+		}{
+			{"all", entity.TaskFilter{}, ids},
+			{"by status", entity.TaskFilter{Statuses: []entity.TaskResultStatus{entity.TaskStatusDone}}, []string{ids[1], ids[3]}},
+			{"by method", entity.TaskFilter{Methods: []entity.TaskMethod{entity.MethodGet}}, []string{ids[0], ids[2], ids[4]}},
+			{"by host", entity.TaskFilter{Host: "example.com"}, []string{ids[0], ids[1], ids[3], ids[4]}},
+			{"by owner", entity.TaskFilter{Owner: "alice"}, []string{ids[0], ids[2]}},
+			{
+				"by created range",
+				entity.TaskFilter{CreatedAfter: *first.CreatedAt, CreatedBefore: *last.CreatedAt},
+				ids[:len(ids)-1],
+			},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				got := listAll(t, repo, tt.filter)
+				if !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("List() got = %v, want %v", got, tt.want)
+				}
+			})
+		}
+
+		if _, err := repo.List(context.Background(), &entity.TaskFilter{Cursor: "not a cursor"}); !errors.Is(err, repository.ErrInvalidCursor) {
+			t.Errorf("List() error = %v, want %v", err, repository.ErrInvalidCursor)
+		}
+	})
+
+	t.Run("update keeps task owner", func(t *testing.T) {
+		repo := newRepo(t)
+
+		id, err := repo.Create(context.Background(), &entity.Task{Owner: "alice"})
+		if err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+
+		if err = repo.Update(context.Background(), &entity.TaskResult{ID: id, Status: entity.TaskStatusDone}); err != nil {
+			t.Fatalf("Update() error = %v", err)
+		}
+
+		got, err := repo.GetByID(context.Background(), id)
+		if err != nil {
+			t.Fatalf("GetByID() error = %v", err)
+		}
+		if got.Owner != "alice" {
+			t.Errorf("GetByID() owner = %q, want %q", got.Owner, "alice")
+		}
+	})
+
+	t.Run("update non-existent task result", func(t *testing.T) {
+		repo := newRepo(t)
+
+		err := repo.Update(context.Background(), &entity.TaskResult{ID: uuid.New().String(), Status: entity.TaskStatusDone})
+		if !errors.Is(err, repository.ErrNotFound) {
+			t.Errorf("Update() error = %v, want %v", err, repository.ErrNotFound)
+		}
+	})
+
+	t.Run("delete task result", func(t *testing.T) {
+		repo := newRepo(t)
+
+		id, err := repo.Create(context.Background(), &entity.Task{})
+		if err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+
+		if err = repo.Delete(context.Background(), id); err != nil {
+			t.Errorf("Delete() error = %v", err)
+		}
+
+		if _, err = repo.GetByID(context.Background(), id); !errors.Is(err, repository.ErrNotFound) {
+			t.Errorf("GetByID() after delete error = %v, want %v", err, repository.ErrNotFound)
+		}
+
+		if err = repo.Delete(context.Background(), id); !errors.Is(err, repository.ErrNotFound) {
+			t.Errorf("Delete() error = %v, want %v", err, repository.ErrNotFound)
+		}
+	})
+
+	t.Run("evict finished task results by age", func(t *testing.T) {
+		repo := newRepo(t)
+
+		done := createWithResult(t, repo, &entity.TaskResult{Status: entity.TaskStatusDone})
+		pendingCallback := createWithResult(t, repo, &entity.TaskResult{
+			Status:   entity.TaskStatusError,
+			Callback: &entity.CallbackDelivery{Status: entity.CallbackStatusPending},
+		})
+		inProcess := createWithResult(t, repo, &entity.TaskResult{Status: entity.TaskStatusInProcess})
+
+		n, err := repo.Evict(context.Background(), time.Now().Add(time.Hour), 0)
+		if err != nil {
+			t.Fatalf("Evict() error = %v", err)
+		}
+		if n != 1 {
+			t.Errorf("Evict() = %d, want 1", n)
+		}
+
+		if got := listAll(t, repo, entity.TaskFilter{}); !sameIDs(got, []string{pendingCallback, inProcess}) {
+			t.Errorf("List() after evict = %v, want %v", got, []string{pendingCallback, inProcess})
+		}
+
+		if n, err = repo.Evict(context.Background(), time.Now().Add(-time.Hour), 0); err != nil || n != 0 {
+			t.Errorf("Evict() = %d, %v, want nothing evicted", n, err)
+		}
+
+		if _, err = repo.GetByID(context.Background(), done); !errors.Is(err, repository.ErrNotFound) {
+			t.Errorf("GetByID() error = %v, want %v", err, repository.ErrNotFound)
+		}
+	})
+
+	t.Run("evict least recently used task results", func(t *testing.T) {
+		repo := newRepo(t)
+
+		first := createWithResult(t, repo, &entity.TaskResult{Status: entity.TaskStatusDone})
+		second := createWithResult(t, repo, &entity.TaskResult{Status: entity.TaskStatusCancelled})
+		third := createWithResult(t, repo, &entity.TaskResult{Status: entity.TaskStatusError})
+		pending, err := repo.Create(context.Background(), &entity.Task{})
+		if err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+
+		if _, err = repo.GetByID(context.Background(), first); err != nil {
+			t.Fatalf("GetByID() error = %v", err)
+		}
+
+		n, err := repo.Evict(context.Background(), time.Time{}, 2)
+		if err != nil {
+			t.Fatalf("Evict() error = %v", err)
+		}
+		if n != 2 {
+			t.Errorf("Evict() = %d, want 2", n)
+		}
+
+		want := []string{first, pending}
+		if got := listAll(t, repo, entity.TaskFilter{}); !sameIDs(got, want) {
+			t.Errorf("List() after evict = %v, want %v (evicted %s and %s)", got, want, second, third)
+		}
+	})
+}
+
+func createWithResult(t *testing.T, repo service.Repository, res *entity.TaskResult) string {
+	t.Helper()
+
+	id, err := repo.Create(context.Background(), &entity.Task{})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	res.ID = id
+	if err = repo.Update(context.Background(), res); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+
+	return id
+}
+
+func sameIDs(got, want []string) bool {
+	if len(got) != len(want) {
+		return false
+	}
+
+	seen := make(map[string]bool, len(got))
+	for _, id := range got {
+		seen[id] = true
+	}
+
+	for _, id := range want {
+		if !seen[id] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func listAll(t *testing.T, repo service.Repository, filter entity.TaskFilter) []string {
+	t.Helper()
+
+	filter.Limit = 2
+
+	var ids []string
+	for {
+		page, err := repo.List(context.Background(), &filter)
+		if err != nil {
+			t.Fatalf("List() error = %v", err)
+		}
+
+		if len(page.Items) > filter.Limit {
+			t.Fatalf("List() returned %d items, limit %d", len(page.Items), filter.Limit)
+		}
+
+		for _, item := range page.Items {
+			ids = append(ids, item.ID)
+		}
+
+		if page.NextCursor == "" {
+			return ids
+		}
+
+		filter.Cursor = page.NextCursor
+	}
+}
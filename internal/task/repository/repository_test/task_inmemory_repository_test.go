@@ -70,6 +70,7 @@ func TestTaskInMemoryRepository_Create(t *testing.T) {
 func TestTaskInMemoryRepository_GetByID(t *testing.T) {
 	repo := repository.NewTaskInMemoryRepository()
 	taskID, _ := repo.Create(context.Background(), &entity.Task{})
+	created, _ := repo.GetByID(context.Background(), taskID)
 
 	type fields struct {
 		repo *repository.TaskInMemoryRepository
@@ -97,6 +98,7 @@ func TestTaskInMemoryRepository_GetByID(t *testing.T) {
 			want: &entity.TaskResult{
 				ID:             taskID,
 				Status:         entity.TaskStatusNew,
+				CreatedAt:      created.CreatedAt,
 				HTTPStatusCode: 0,
 				Headers:        nil,
 				Length:         0,
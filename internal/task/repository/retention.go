@@ -0,0 +1,66 @@
+package repository
+
+import (
+	"sort"
+	"time"
+
+	"github.com/Mi7teR/aggregator/internal/task/entity"
+)
+
+type evictionCandidate struct {
+	id         string
+	finishedAt time.Time
+	accessedAt time.Time
+}
+
+// evictable reports whether a result may be removed. Unfinished tasks and
+// tasks with a pending callback delivery are kept.
+func evictable(res *entity.TaskResult) bool {
+	if !res.Status.Terminal() {
+		return false
+	}
+
+	return !pendingCallback(res)
+}
+
+func pendingCallback(res *entity.TaskResult) bool {
+	return res.Callback != nil && res.Callback.Status == entity.CallbackStatusPending
+}
+
+// selectEvictions returns the candidates finished before finishedBefore and,
+// when more than maxResults records remain, the least recently used of the
+// rest. A zero finishedBefore or maxResults disables the respective limit.
+func selectEvictions(candidates []evictionCandidate, total int, finishedBefore time.Time, maxResults int) []string {
+	var (
+		ids  []string
+		rest []evictionCandidate
+	)
+
+	for _, c := range candidates {
+		if !finishedBefore.IsZero() && c.finishedAt.Before(finishedBefore) {
+			ids = append(ids, c.id)
+			continue
+		}
+
+		rest = append(rest, c)
+	}
+
+	excess := total - len(ids) - maxResults
+	if maxResults <= 0 || excess <= 0 {
+		return ids
+	}
+
+	sort.Slice(rest, func(i, j int) bool {
+		return rest[i].accessedAt.Before(rest[j].accessedAt)
+	})
+
+	if excess > len(rest) {
+		excess = len(rest)
+	}
+
+	for _, c := range rest[:excess] {
+		ids = append(ids, c.id)
+	}
+
+	return ids
+}
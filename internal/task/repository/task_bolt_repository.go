@@ -0,0 +1,352 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/Mi7teR/aggregator/internal/logger"
+	"github.com/Mi7teR/aggregator/internal/task/entity"
+	"github.com/google/uuid"
+	"go.etcd.io/bbolt"
+)
+
+const (
+	boltBucketResults = "task_results"
+	boltFileMode      = 0o600
+	boltOpenTimeout   = time.Second
+
+	interruptedMessage = "interrupted by restart"
+)
+
+// TaskBoltRepository keeps last access times in memory only, so reads do
+// not turn into writes. After a restart records fall back to the time they
+// finished for LRU eviction.
+type TaskBoltRepository struct {
+	db *bbolt.DB
+
+	accessMu sync.Mutex
+	accessed map[string]time.Time
+}
+
+type boltRecord struct {
+	Task       taskSummary       `json:"task"`
+	Result     entity.TaskResult `json:"result"`
+	Body       []byte            `json:"body,omitempty"`
+	FinishedAt *time.Time        `json:"finishedAt,omitempty"`
+	Owner      string            `json:"owner,omitempty"`
+}
+
+func NewTaskBoltRepository(path string) (*TaskBoltRepository, error) {
+	db, err := bbolt.Open(path, boltFileMode, &bbolt.Options{Timeout: boltOpenTimeout})
+	if err != nil {
+		return nil, fmt.Errorf("open bolt db: %w", err)
+	}
+
+	err = db.Update(func(tx *bbolt.Tx) error {
+		_, errBucket := tx.CreateBucketIfNotExists([]byte(boltBucketResults))
+		return errBucket
+	})
+	if err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("create bolt bucket: %w", err)
+	}
+
+	interrupted, err := failInterrupted(db)
+	if err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("fail interrupted tasks: %w", err)
+	}
+	if interrupted > 0 {
+		slog.Warn("failed tasks and callback deliveries interrupted by restart", "count", interrupted)
+	}
+
+	return &TaskBoltRepository{db: db, accessed: make(map[string]time.Time)}, nil
+}
+
+// failInterrupted marks tasks that were still queued or running when the
+// process stopped as cancelled errors, and callback deliveries that were
+// still pending as failed. Nothing resumes them, so they would otherwise
+// never reach a terminal status nor be evicted.
+func failInterrupted(db *bbolt.DB) (int, error) {
+	var count int
+
+	err := db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte(boltBucketResults))
+
+		var records []*boltRecord
+		err := b.ForEach(func(k, v []byte) error {
+			rec, errDecode := decodeRecord(v)
+			if errDecode != nil {
+				return errDecode
+			}
+
+			if !rec.Result.Status.Terminal() || pendingCallback(&rec.Result) {
+				records = append(records, rec)
+			}
+
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		finishedAt := time.Now().UTC()
+		for _, rec := range records {
+			if pendingCallback(&rec.Result) {
+				rec.Result.Callback.Status = entity.CallbackStatusFailed
+				rec.Result.Callback.LastError = interruptedMessage
+			}
+
+			if !rec.Result.Status.Terminal() {
+				rec.Result.Status = entity.TaskStatusError
+				rec.Result.Error = interruptedMessage
+				rec.Result.ErrorKind = entity.ErrorKindCancelled
+				rec.Result.FinishedAt = &finishedAt
+				rec.FinishedAt = &finishedAt
+			}
+
+			if err = putRecord(b, rec); err != nil {
+				return err
+			}
+		}
+		count = len(records)
+
+		return nil
+	})
+
+	return count, err
+}
+
+func (t *TaskBoltRepository) Close() error {
+	return t.db.Close()
+}
+
+func (t *TaskBoltRepository) Create(ctx context.Context, task *entity.Task) (string, error) {
+	createdAt := time.Now().UTC()
+	rec := &boltRecord{
+		Task: summarize(task),
+		Result: entity.TaskResult{
+			ID:             uuid.New().String(),
+			Status:         entity.TaskStatusNew,
+			CreatedAt:      &createdAt,
+			HTTPStatusCode: 0,
+			Headers:        nil,
+			Length:         0,
+			Owner:          task.Owner,
+		},
+		Owner: task.Owner,
+	}
+
+	err := t.db.Update(func(tx *bbolt.Tx) error {
+		return putRecord(tx.Bucket([]byte(boltBucketResults)), rec)
+	})
+	if err != nil {
+		return "", err
+	}
+
+	t.touch(rec.Result.ID)
+	slog.DebugContext(logger.WithTaskID(ctx, rec.Result.ID), "task result created")
+
+	return rec.Result.ID, nil
+}
+
+func (t *TaskBoltRepository) GetByID(ctx context.Context, id string) (*entity.TaskResult, error) {
+	var rec *boltRecord
+
+	err := t.db.View(func(tx *bbolt.Tx) error {
+		var errGet error
+		rec, errGet = getRecord(tx.Bucket([]byte(boltBucketResults)), id)
+		return errGet
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	t.touch(id)
+
+	return &rec.Result, nil
+}
+
+func (t *TaskBoltRepository) Update(ctx context.Context, res *entity.TaskResult) error {
+	err := t.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte(boltBucketResults))
+
+		rec, err := getRecord(b, res.ID)
+		if err != nil {
+			return err
+		}
+
+		if res.Status.Terminal() && !rec.Result.Status.Terminal() {
+			finishedAt := time.Now().UTC()
+			rec.FinishedAt = &finishedAt
+		}
+
+		createdAt := rec.Result.CreatedAt
+		rec.Result = *res
+		rec.Result.CreatedAt = createdAt
+		rec.Result.Owner = rec.Owner
+		rec.Body = res.Body
+
+		return putRecord(b, rec)
+	})
+	if err != nil {
+		return err
+	}
+
+	t.touch(res.ID)
+	slog.DebugContext(ctx, "task result updated", "status", res.Status.String())
+
+	return nil
+}
+
+func (t *TaskBoltRepository) Delete(ctx context.Context, id string) error {
+	err := t.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte(boltBucketResults))
+		if b.Get([]byte(id)) == nil {
+			return ErrNotFound
+		}
+
+		return b.Delete([]byte(id))
+	})
+	if err != nil {
+		return err
+	}
+
+	t.forget([]string{id})
+	slog.DebugContext(ctx, "task result deleted")
+
+	return nil
+}
+
+func (t *TaskBoltRepository) Evict(ctx context.Context, finishedBefore time.Time, maxResults int) (int, error) {
+	var ids []string
+
+	err := t.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte(boltBucketResults))
+
+		var (
+			candidates []evictionCandidate
+			total      int
+		)
+		err := b.ForEach(func(k, v []byte) error {
+			total++
+
+			rec, errDecode := decodeRecord(v)
+			if errDecode != nil {
+				return errDecode
+			}
+
+			if evictable(&rec.Result) && rec.FinishedAt != nil {
+				candidates = append(candidates, evictionCandidate{
+					id:         rec.Result.ID,
+					finishedAt: *rec.FinishedAt,
+					accessedAt: t.accessedAt(rec.Result.ID, *rec.FinishedAt),
+				})
+			}
+
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		ids = selectEvictions(candidates, total, finishedBefore, maxResults)
+		for _, id := range ids {
+			if err = b.Delete([]byte(id)); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	t.forget(ids)
+
+	return len(ids), nil
+}
+
+func (t *TaskBoltRepository) touch(id string) {
+	t.accessMu.Lock()
+	t.accessed[id] = time.Now()
+	t.accessMu.Unlock()
+}
+
+func (t *TaskBoltRepository) forget(ids []string) {
+	t.accessMu.Lock()
+	for _, id := range ids {
+		delete(t.accessed, id)
+	}
+	t.accessMu.Unlock()
+}
+
+func (t *TaskBoltRepository) accessedAt(id string, fallback time.Time) time.Time {
+	t.accessMu.Lock()
+	defer t.accessMu.Unlock()
+
+	if at, ok := t.accessed[id]; ok {
+		return at
+	}
+
+	return fallback
+}
+
+func (t *TaskBoltRepository) List(ctx context.Context, filter *entity.TaskFilter) (*entity.TaskList, error) {
+	var records []entity.TaskResult
+
+	err := t.db.View(func(tx *bbolt.Tx) error {
+		return tx.Bucket([]byte(boltBucketResults)).ForEach(func(k, v []byte) error {
+			rec, err := decodeRecord(v)
+			if err != nil {
+				return err
+			}
+
+			if matchFilter(filter, &rec.Task, &rec.Result) {
+				records = append(records, rec.Result)
+			}
+
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return paginate(filter, records)
+}
+
+func putRecord(b *bbolt.Bucket, rec *boltRecord) error {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("marshal task result: %w", err)
+	}
+
+	return b.Put([]byte(rec.Result.ID), data)
+}
+
+func getRecord(b *bbolt.Bucket, id string) (*boltRecord, error) {
+	data := b.Get([]byte(id))
+	if data == nil {
+		return nil, ErrNotFound
+	}
+
+	return decodeRecord(data)
+}
+
+func decodeRecord(data []byte) (*boltRecord, error) {
+	var rec boltRecord
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return nil, fmt.Errorf("unmarshal task result: %w", err)
+	}
+
+	rec.Result.Body = rec.Body
+	rec.Result.Owner = rec.Owner
+
+	return &rec, nil
+}
@@ -3,62 +3,142 @@ package repository
 import (
 	"context"
 	"errors"
+	"log/slog"
 	"sync"
+	"time"
 
+	"github.com/Mi7teR/aggregator/internal/logger"
 	"github.com/Mi7teR/aggregator/internal/task/entity"
 	"github.com/google/uuid"
 )
 
 type TaskInMemoryRepository struct {
 	mu   sync.RWMutex
-	data map[string]entity.TaskResult
+	data map[string]inMemoryRecord
+}
+
+type inMemoryRecord struct {
+	task       taskSummary
+	result     entity.TaskResult
+	finishedAt time.Time
+	accessedAt time.Time
 }
 
 var ErrNotFound = errors.New("task result not found")
 
 func NewTaskInMemoryRepository() *TaskInMemoryRepository {
-	return &TaskInMemoryRepository{data: make(map[string]entity.TaskResult)}
+	return &TaskInMemoryRepository{data: make(map[string]inMemoryRecord)}
 }
 
 func (t *TaskInMemoryRepository) Create(ctx context.Context, task *entity.Task) (string, error) {
 	t.mu.Lock()
 	defer t.mu.Unlock()
 
+	createdAt := time.Now().UTC()
 	newTask := entity.TaskResult{
 		ID:             uuid.New().String(),
 		Status:         entity.TaskStatusNew,
+		CreatedAt:      &createdAt,
 		HTTPStatusCode: 0,
 		Headers:        nil,
 		Length:         0,
+		Owner:          task.Owner,
 	}
 
-	t.data[newTask.ID] = newTask
+	t.data[newTask.ID] = inMemoryRecord{task: summarize(task), result: newTask, accessedAt: createdAt}
+	slog.DebugContext(logger.WithTaskID(ctx, newTask.ID), "task result created")
 
 	return newTask.ID, nil
 }
 
 func (t *TaskInMemoryRepository) GetByID(ctx context.Context, id string) (*entity.TaskResult, error) {
-	t.mu.RLock()
-	defer t.mu.RUnlock()
+	t.mu.Lock()
+	defer t.mu.Unlock()
 
 	v, ok := t.data[id]
 	if !ok {
 		return nil, ErrNotFound
 	}
 
-	return &v, nil
+	v.accessedAt = time.Now()
+	t.data[id] = v
+
+	return &v.result, nil
 }
 
 func (t *TaskInMemoryRepository) Update(ctx context.Context, res *entity.TaskResult) error {
 	t.mu.Lock()
 	defer t.mu.Unlock()
 
-	_, ok := t.data[res.ID]
+	v, ok := t.data[res.ID]
 	if !ok {
 		return ErrNotFound
 	}
 
-	t.data[res.ID] = *res
+	now := time.Now()
+	if res.Status.Terminal() && !v.result.Status.Terminal() {
+		v.finishedAt = now
+	}
+
+	createdAt, owner := v.result.CreatedAt, v.result.Owner
+	v.result = *res
+	v.result.CreatedAt = createdAt
+	v.result.Owner = owner
+	v.accessedAt = now
+	t.data[res.ID] = v
+	slog.DebugContext(ctx, "task result updated", "status", res.Status.String())
+
+	return nil
+}
+
+func (t *TaskInMemoryRepository) Delete(ctx context.Context, id string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if _, ok := t.data[id]; !ok {
+		return ErrNotFound
+	}
+
+	delete(t.data, id)
+	slog.DebugContext(ctx, "task result deleted")
 
 	return nil
 }
+
+func (t *TaskInMemoryRepository) Evict(ctx context.Context, finishedBefore time.Time, maxResults int) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var candidates []evictionCandidate
+	for id := range t.data {
+		v := t.data[id]
+		if evictable(&v.result) {
+			candidates = append(candidates, evictionCandidate{
+				id:         id,
+				finishedAt: v.finishedAt,
+				accessedAt: v.accessedAt,
+			})
+		}
+	}
+
+	ids := selectEvictions(candidates, len(t.data), finishedBefore, maxResults)
+	for _, id := range ids {
+		delete(t.data, id)
+	}
+
+	return len(ids), nil
+}
+
+func (t *TaskInMemoryRepository) List(ctx context.Context, filter *entity.TaskFilter) (*entity.TaskList, error) {
+	t.mu.RLock()
+	records := make([]entity.TaskResult, 0, len(t.data))
+	for id := range t.data {
+		v := t.data[id]
+		if matchFilter(filter, &v.task, &v.result) {
+			records = append(records, v.result)
+		}
+	}
+	t.mu.RUnlock()
+
+	return paginate(filter, records)
+}
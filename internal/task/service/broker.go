@@ -0,0 +1,82 @@
+package service
+
+import (
+	"sync"
+
+	"github.com/Mi7teR/aggregator/internal/task/entity"
+)
+
+const subscriptionBuffer = 16
+
+type subscription struct {
+	match func(res *entity.TaskResult) bool
+	ch    chan entity.TaskResult
+}
+
+// broker fans out task status changes to subscribers. Slow subscribers miss
+// events instead of blocking task execution.
+type broker struct {
+	mu     sync.Mutex
+	subs   map[*subscription]struct{}
+	closed bool
+}
+
+func newBroker() *broker {
+	return &broker{subs: make(map[*subscription]struct{})}
+}
+
+func (b *broker) subscribe(match func(res *entity.TaskResult) bool) (<-chan entity.TaskResult, func()) {
+	sub := &subscription{match: match, ch: make(chan entity.TaskResult, subscriptionBuffer)}
+
+	b.mu.Lock()
+	if b.closed {
+		close(sub.ch)
+	} else {
+		b.subs[sub] = struct{}{}
+	}
+	b.mu.Unlock()
+
+	return sub.ch, func() {
+		b.mu.Lock()
+		delete(b.subs, sub)
+		b.mu.Unlock()
+	}
+}
+
+func (b *broker) publish(res *entity.TaskResult) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for sub := range b.subs {
+		if !sub.match(res) {
+			continue
+		}
+
+		select {
+		case sub.ch <- *res:
+		default:
+		}
+	}
+}
+
+// close ends all subscriptions by closing their channels.
+func (b *broker) close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return
+	}
+
+	b.closed = true
+	for sub := range b.subs {
+		close(sub.ch)
+		delete(b.subs, sub)
+	}
+}
+
+func matchID(id string) func(res *entity.TaskResult) bool {
+	return func(res *entity.TaskResult) bool {
+		return res.ID == id
+	}
+}
@@ -0,0 +1,139 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/Mi7teR/aggregator/internal/task/entity"
+)
+
+const (
+	DefaultCallbackAttempts = 5
+	callbackTimeout         = 10 * time.Second
+	callbackBackoffBase     = time.Second
+	callbackBackoffMax      = 30 * time.Second
+
+	HeaderCallbackTaskID    = "X-Aggregator-Task-Id"
+	HeaderCallbackSignature = "X-Aggregator-Signature"
+)
+
+var errCallbackInterrupted = errors.New("callback delivery interrupted by shutdown")
+
+func hasCallback(task *entity.Task) bool {
+	return task != nil && task.Callback != nil
+}
+
+// notify starts the callback delivery of a final result that has already
+// been stored with a pending callback status. The delivery outlives the
+// task, but not the shutdown deadline of the service.
+func (s *Service) notify(ctx context.Context, task *entity.Task, res *entity.TaskResult) {
+	if !hasCallback(task) {
+		return
+	}
+
+	payload := *res
+	payload.Callback = nil
+
+	ctx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	stop := context.AfterFunc(s.callbackCtx, cancel)
+
+	s.callbacks.Add(1)
+	go func() {
+		defer s.callbacks.Done()
+		defer cancel()
+		defer stop()
+		s.deliver(ctx, task.Callback, &payload)
+	}()
+}
+
+// deliver posts the final task result to the callback url, retrying with
+// backoff, and records the delivery outcome on the stored result.
+func (s *Service) deliver(ctx context.Context, cb *entity.TaskCallback, res *entity.TaskResult) {
+	body, err := json.Marshal(res)
+	if err != nil {
+		slog.ErrorContext(ctx, "encode callback payload", "error", err)
+		return
+	}
+
+	policy := newRetryPolicy(&entity.TaskRetry{
+		MaxAttempts: s.callbackAttempts,
+		BackoffBase: entity.Duration(callbackBackoffBase),
+		BackoffMax:  entity.Duration(callbackBackoffMax),
+	})
+	delivery := &entity.CallbackDelivery{Status: entity.CallbackStatusFailed}
+
+	for n := 1; n <= policy.maxAttempts; n++ {
+		delivery.Attempts = n
+
+		code, errPost := s.postCallback(ctx, cb, res.ID, body)
+		delivery.HTTPStatusCode = code
+		if errPost == nil {
+			deliveredAt := time.Now().UTC()
+			delivery.Status = entity.CallbackStatusDelivered
+			delivery.LastError = ""
+			delivery.DeliveredAt = &deliveredAt
+			break
+		}
+
+		slog.WarnContext(ctx, "callback delivery failed", "attempt", n, "error", errPost)
+		delivery.LastError = errPost.Error()
+
+		if n < policy.maxAttempts && !sleep(ctx, policy.delay(n, nil)) {
+			break
+		}
+	}
+
+	if delivery.Status != entity.CallbackStatusDelivered && ctx.Err() != nil {
+		delivery.LastError = errCallbackInterrupted.Error()
+	}
+
+	res.Callback = delivery
+	if err = s.repo.Update(context.WithoutCancel(ctx), res); err != nil {
+		slog.ErrorContext(ctx, "store callback delivery", "error", err)
+	}
+}
+
+func (s *Service) postCallback(ctx context.Context, cb *entity.TaskCallback, id string, body []byte) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, callbackTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, cb.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderCallbackTaskID, id)
+	if cb.Secret != "" {
+		req.Header.Set(HeaderCallbackSignature, Sign(cb.Secret, body))
+	}
+
+	res, err := s.callbackClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer drain(res)
+
+	if res.StatusCode < http.StatusOK || res.StatusCode >= http.StatusMultipleChoices {
+		return res.StatusCode, fmt.Errorf("callback responded with status %d", res.StatusCode)
+	}
+
+	return res.StatusCode, nil
+}
+
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	_, _ = io.Copy(mac, bytes.NewReader(body))
+
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,73 @@
+package service
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+	"strings"
+	"syscall"
+
+	"github.com/Mi7teR/aggregator/internal/policy"
+	"github.com/Mi7teR/aggregator/internal/task/entity"
+)
+
+var (
+	ErrUnsupportedScheme = errors.New("unsupported url scheme")
+	ErrMissingHost       = errors.New("url has no host")
+	errTaskCancelled     = errors.New("task cancelled")
+)
+
+type invalidRequestError struct {
+	err error
+}
+
+func (e *invalidRequestError) Error() string {
+	return e.err.Error()
+}
+
+func (e *invalidRequestError) Unwrap() error {
+	return e.err
+}
+
+func classifyError(err error) entity.TaskErrorKind {
+	var (
+		invalidErr   *invalidRequestError
+		dnsErr       *net.DNSError
+		certErr      *tls.CertificateVerificationError
+		recordErr    tls.RecordHeaderError
+		authorityErr x509.UnknownAuthorityError
+		hostnameErr  x509.HostnameError
+		netErr       net.Error
+	)
+
+	switch {
+	case errors.As(err, &invalidErr), errors.Is(err, ErrTooManyRedirects):
+		return entity.ErrorKindInvalidRequest
+	case errors.Is(err, policy.ErrDestinationNotAllowed):
+		return entity.ErrorKindDestinationNotAllowed
+	case errors.Is(err, context.Canceled), errors.Is(err, errTaskCancelled), errors.Is(err, ErrShutdown):
+		return entity.ErrorKindCancelled
+	case errors.As(err, &dnsErr):
+		return entity.ErrorKindDNS
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return entity.ErrorKindConnectionRefused
+	case errors.As(err, &certErr), errors.As(err, &recordErr), errors.As(err, &authorityErr),
+		errors.As(err, &hostnameErr), strings.Contains(err.Error(), "tls: "):
+		return entity.ErrorKindTLS
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return entity.ErrorKindTimeout
+	default:
+		return entity.ErrorKindUnknown
+	}
+}
+
+func errorResult(id string, err error) *entity.TaskResult {
+	return &entity.TaskResult{
+		ID:        id,
+		Status:    entity.TaskStatusError,
+		Error:     err.Error(),
+		ErrorKind: classifyError(err),
+	}
+}
@@ -0,0 +1,158 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/Mi7teR/aggregator/internal/task/entity"
+)
+
+type execution struct {
+	task      *entity.Task
+	cancel    context.CancelFunc
+	cancelled bool
+}
+
+func (s *Service) track(id string, task *entity.Task) {
+	s.execMu.Lock()
+	defer s.execMu.Unlock()
+
+	s.executions[id] = &execution{task: task}
+}
+
+// untrack drops the execution of a task that will not run any further and
+// frees its place in the client quota, unless CancelTask already did.
+func (s *Service) untrack(id string) {
+	e, ok := s.executions[id]
+	if !ok {
+		return
+	}
+
+	delete(s.executions, id)
+	if !e.cancelled {
+		s.quota.release(e.task.Owner)
+	}
+}
+
+// parked registers the cancel func of a task waiting for its host, so
+// CancelTask can end the wait.
+func (s *Service) parked(id string, cancel context.CancelFunc) {
+	s.execMu.Lock()
+	defer s.execMu.Unlock()
+
+	e, ok := s.executions[id]
+	if !ok || e.cancelled {
+		cancel()
+		return
+	}
+
+	e.cancel = cancel
+}
+
+// begin marks the task as in process and registers its cancel func. It
+// reports false when the task was cancelled while waiting in the queue.
+func (s *Service) begin(
+	ctx context.Context,
+	id string,
+	task *entity.Task,
+	cancel context.CancelFunc,
+	startedAt *time.Time,
+) bool {
+	s.execMu.Lock()
+	defer s.execMu.Unlock()
+
+	e, ok := s.executions[id]
+	if !ok {
+		e = &execution{task: task}
+		s.executions[id] = e
+	}
+
+	if e.cancelled {
+		s.untrack(id)
+		return false
+	}
+
+	res := &entity.TaskResult{
+		ID:        id,
+		Status:    entity.TaskStatusInProcess,
+		StartedAt: startedAt,
+		Owner:     task.Owner,
+	}
+	if err := s.repo.Update(ctx, res); err != nil {
+		s.untrack(id)
+		slog.ErrorContext(ctx, "store task status", "status", res.Status.String(), "error", err)
+		return false
+	}
+
+	slog.DebugContext(ctx, "task started")
+	s.broker.publish(res)
+	e.cancel = cancel
+	if s.aborted {
+		cancel()
+	}
+
+	return true
+}
+
+// abandon fails a queued task that will not run because the service is
+// shutting down.
+func (s *Service) abandon(ctx context.Context, id string, task *entity.Task) {
+	res := errorResult(id, ErrShutdown)
+	res.Owner = task.Owner
+
+	slog.DebugContext(ctx, "task dropped from queue")
+	s.finish(ctx, id, res)
+}
+
+// cancelExecutions cancels the running tasks, and any that start later,
+// once the shutdown deadline has passed.
+func (s *Service) cancelExecutions() {
+	s.execMu.Lock()
+	defer s.execMu.Unlock()
+
+	s.aborted = true
+	for _, e := range s.executions {
+		if e.cancel != nil {
+			e.cancel()
+		}
+	}
+}
+
+// finish stores the final result unless the task was cancelled meanwhile,
+// in which case CancelTask has already stored the cancelled status.
+func (s *Service) finish(ctx context.Context, id string, res *entity.TaskResult) {
+	s.execMu.Lock()
+	defer s.execMu.Unlock()
+
+	e, ok := s.executions[id]
+	s.untrack(id)
+
+	if ok && e.cancelled {
+		return
+	}
+
+	finishedAt := time.Now().UTC()
+	res.FinishedAt = &finishedAt
+
+	if ok && hasCallback(e.task) {
+		res.Callback = &entity.CallbackDelivery{Status: entity.CallbackStatusPending}
+	}
+
+	if err := s.repo.Update(ctx, res); err != nil {
+		slog.ErrorContext(ctx, "store task result", "status", res.Status.String(), "error", err)
+		return
+	}
+
+	slog.InfoContext(ctx, "task finished",
+		"status", res.Status.String(),
+		"http_status", res.HTTPStatusCode,
+		"attempts", res.AttemptCount,
+	)
+	s.broker.publish(res)
+	s.metrics.TaskFinished(res.Status)
+
+	if ok {
+		s.notify(ctx, e.task, res)
+	}
+}
@@ -0,0 +1,316 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/Mi7teR/aggregator/internal/ratelimit"
+	"github.com/Mi7teR/aggregator/internal/task/entity"
+)
+
+const (
+	hostLimitMinParts = 2
+	hostLimitMaxParts = 3
+)
+
+var (
+	ErrInvalidHostLimit  = errors.New("invalid host limit")
+	errHostLimitFormat   = errors.New("expected concurrency/rps[/burst]")
+	errNegativeHostLimit = errors.New("limits must not be negative")
+)
+
+// HostLimit bounds the requests sent to a single destination host. Zero
+// values mean no limit. Burst defaults to the rate rounded up.
+type HostLimit struct {
+	MaxConcurrent int
+	RPS           float64
+	Burst         int
+}
+
+func (l HostLimit) enabled() bool {
+	return l.MaxConcurrent > 0 || l.RPS > 0
+}
+
+func (l HostLimit) limiter() *ratelimit.Limiter {
+	if l.RPS <= 0 {
+		return nil
+	}
+
+	burst := l.Burst
+	if burst <= 0 {
+		burst = int(math.Ceil(l.RPS))
+	}
+
+	return ratelimit.New(l.RPS, burst)
+}
+
+// hostLimiter makes attempts against the same host wait for a free
+// connection slot and for their turn in the host request rate.
+type hostLimiter struct {
+	def       HostLimit
+	overrides map[string]HostLimit
+
+	defRate      *ratelimit.Limiter
+	overrideRate map[string]*ratelimit.Limiter
+
+	mu    sync.Mutex
+	slots map[string]*hostSlots
+}
+
+type hostSlots struct {
+	sem   chan struct{}
+	freed chan struct{}
+	refs  int
+}
+
+func newHostLimiter(def HostLimit, overrides map[string]HostLimit) *hostLimiter {
+	l := &hostLimiter{
+		def:          def,
+		overrides:    make(map[string]HostLimit, len(overrides)),
+		defRate:      def.limiter(),
+		overrideRate: make(map[string]*ratelimit.Limiter, len(overrides)),
+		slots:        make(map[string]*hostSlots),
+	}
+
+	for host, limit := range overrides {
+		host = strings.ToLower(host)
+		l.overrides[host] = limit
+		l.overrideRate[host] = limit.limiter()
+	}
+
+	return l
+}
+
+func (l *hostLimiter) limit(host string) (HostLimit, *ratelimit.Limiter) {
+	if limit, ok := l.overrides[host]; ok {
+		return limit, l.overrideRate[host]
+	}
+
+	return l.def, l.defRate
+}
+
+// acquire blocks until a request to host may be sent. The returned func
+// frees the connection slot once the response is done with.
+func (l *hostLimiter) acquire(ctx context.Context, host string) (func(), error) {
+	host = strings.ToLower(host)
+	limit, rate := l.limit(host)
+	if !limit.enabled() {
+		return func() {}, nil
+	}
+
+	release := func() {}
+	if limit.MaxConcurrent > 0 {
+		slots := l.ref(host, limit.MaxConcurrent)
+
+		select {
+		case slots.sem <- struct{}{}:
+		case <-ctx.Done():
+			l.unref(host)
+			return nil, ctx.Err()
+		}
+
+		release = l.releaser(host, slots)
+	}
+
+	if rate != nil {
+		if err := rate.Wait(ctx, host); err != nil {
+			release()
+			return nil, err
+		}
+	}
+
+	return release, nil
+}
+
+// tryAcquire is acquire without waiting. It reports false when host has no
+// free connection slot or request token right now.
+func (l *hostLimiter) tryAcquire(host string) (func(), bool) {
+	host = strings.ToLower(host)
+	limit, rate := l.limit(host)
+	if !limit.enabled() {
+		return func() {}, true
+	}
+
+	release := func() {}
+	if limit.MaxConcurrent > 0 {
+		slots := l.ref(host, limit.MaxConcurrent)
+
+		select {
+		case slots.sem <- struct{}{}:
+		default:
+			l.unref(host)
+			return nil, false
+		}
+
+		release = l.releaser(host, slots)
+	}
+
+	if rate != nil && !rate.Allow(host).Allowed {
+		release()
+		return nil, false
+	}
+
+	return release, true
+}
+
+// wait blocks until host has a free connection slot and request token,
+// without taking them. The capacity may be gone again by the time the
+// caller tries to take it.
+func (l *hostLimiter) wait(ctx context.Context, host string) error {
+	host = strings.ToLower(host)
+	limit, rate := l.limit(host)
+	if !limit.enabled() {
+		return nil
+	}
+
+	for {
+		if freed := l.full(host); freed != nil {
+			select {
+			case <-freed:
+				continue
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+
+		if rate == nil {
+			return nil
+		}
+
+		d := rate.Delay(host)
+		if d <= 0 {
+			return nil
+		}
+
+		if !sleep(ctx, d) {
+			return ctx.Err()
+		}
+	}
+}
+
+// full returns a channel closed on the next release when every connection
+// slot of host is taken, and nil otherwise.
+func (l *hostLimiter) full(host string) <-chan struct{} {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	slots, ok := l.slots[host]
+	if !ok || len(slots.sem) < cap(slots.sem) {
+		return nil
+	}
+
+	return slots.freed
+}
+
+func (l *hostLimiter) releaser(host string, slots *hostSlots) func() {
+	var once sync.Once
+
+	return func() {
+		once.Do(func() {
+			<-slots.sem
+
+			l.mu.Lock()
+			close(slots.freed)
+			slots.freed = make(chan struct{})
+			l.mu.Unlock()
+
+			l.unref(host)
+		})
+	}
+}
+
+func (l *hostLimiter) ref(host string, size int) *hostSlots {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	slots, ok := l.slots[host]
+	if !ok {
+		slots = &hostSlots{sem: make(chan struct{}, size), freed: make(chan struct{})}
+		l.slots[host] = slots
+	}
+	slots.refs++
+
+	return slots
+}
+
+func (l *hostLimiter) unref(host string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	slots := l.slots[host]
+	slots.refs--
+	if slots.refs == 0 {
+		delete(l.slots, host)
+	}
+}
+
+func taskHost(task *entity.Task) string {
+	u, err := url.Parse(task.URL)
+	if err != nil {
+		return ""
+	}
+
+	return u.Hostname()
+}
+
+// ParseHostLimits parses per host overrides in the form
+// "host=concurrency/rps[/burst]", e.g. "api.example.com=4/10,slow.org=1/0.5".
+// A zero concurrency or rate leaves that dimension unlimited.
+func ParseHostLimits(values []string) (map[string]HostLimit, error) {
+	limits := make(map[string]HostLimit, len(values))
+
+	for _, v := range values {
+		host, spec, ok := strings.Cut(v, "=")
+		host = strings.ToLower(strings.TrimSpace(host))
+		if !ok || host == "" {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidHostLimit, v)
+		}
+
+		limit, err := parseHostLimit(spec)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %q: %w", ErrInvalidHostLimit, v, err)
+		}
+
+		limits[host] = limit
+	}
+
+	return limits, nil
+}
+
+func parseHostLimit(spec string) (HostLimit, error) {
+	parts := strings.Split(strings.TrimSpace(spec), "/")
+	if len(parts) < hostLimitMinParts || len(parts) > hostLimitMaxParts {
+		return HostLimit{}, errHostLimitFormat
+	}
+
+	var (
+		limit HostLimit
+		err   error
+	)
+
+	if limit.MaxConcurrent, err = strconv.Atoi(parts[0]); err != nil {
+		return HostLimit{}, err
+	}
+
+	if limit.RPS, err = strconv.ParseFloat(parts[1], 64); err != nil {
+		return HostLimit{}, err
+	}
+
+	if len(parts) == hostLimitMaxParts {
+		if limit.Burst, err = strconv.Atoi(parts[2]); err != nil {
+			return HostLimit{}, err
+		}
+	}
+
+	if limit.MaxConcurrent < 0 || limit.RPS < 0 || limit.Burst < 0 {
+		return HostLimit{}, errNegativeHostLimit
+	}
+
+	return limit, nil
+}
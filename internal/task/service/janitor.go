@@ -0,0 +1,48 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+const DefaultJanitorInterval = time.Minute
+
+func (s *Service) retentionEnabled() bool {
+	return s.resultTTL > 0 || s.maxResults > 0
+}
+
+func (s *Service) janitor() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.janitorInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.evict()
+		case <-s.stopCtx.Done():
+			return
+		}
+	}
+}
+
+// evict removes finished results older than the TTL and, above the result
+// limit, the least recently used ones.
+func (s *Service) evict() {
+	var finishedBefore time.Time
+	if s.resultTTL > 0 {
+		finishedBefore = time.Now().Add(-s.resultTTL)
+	}
+
+	n, err := s.repo.Evict(context.Background(), finishedBefore, s.maxResults)
+	if err != nil {
+		slog.Error("evict task results", "error", err)
+		return
+	}
+
+	if n > 0 {
+		slog.Info("evicted task results", "count", n)
+	}
+}
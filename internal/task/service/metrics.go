@@ -0,0 +1,31 @@
+package service
+
+import (
+	"time"
+
+	"github.com/Mi7teR/aggregator/internal/task/entity"
+)
+
+type Metrics interface {
+	TaskCreated(method entity.TaskMethod)
+	TaskFinished(status entity.TaskResultStatus)
+	UpstreamRequest(method entity.TaskMethod, statusCode int, d time.Duration)
+}
+
+type nopMetrics struct{}
+
+func (nopMetrics) TaskCreated(entity.TaskMethod) {}
+
+func (nopMetrics) TaskFinished(entity.TaskResultStatus) {}
+
+func (nopMetrics) UpstreamRequest(entity.TaskMethod, int, time.Duration) {}
+
+// QueueDepth reports the number of tasks waiting for a worker.
+func (s *Service) QueueDepth() int {
+	return len(s.slots)
+}
+
+// InFlight reports the number of tasks currently being executed.
+func (s *Service) InFlight() int {
+	return int(s.inFlight.Load())
+}
@@ -0,0 +1,99 @@
+package service
+
+import (
+	"time"
+
+	"github.com/Mi7teR/aggregator/internal/policy"
+)
+
+type Option func(s *Service)
+
+func WithMaxBodySize(size int64) Option {
+	return func(s *Service) {
+		if size > 0 {
+			s.maxBodySize = size
+		}
+	}
+}
+
+func WithMaxTimeout(d time.Duration) Option {
+	return func(s *Service) {
+		s.maxTimeout = d
+	}
+}
+
+func WithWorkers(n int) Option {
+	return func(s *Service) {
+		if n > 0 {
+			s.workers = n
+		}
+	}
+}
+
+func WithQueueSize(n int) Option {
+	return func(s *Service) {
+		if n > 0 {
+			s.queueSize = n
+		}
+	}
+}
+
+func WithCallbackAttempts(n int) Option {
+	return func(s *Service) {
+		if n > 0 {
+			s.callbackAttempts = n
+		}
+	}
+}
+
+func WithRetention(ttl time.Duration, maxResults int) Option {
+	return func(s *Service) {
+		s.resultTTL = ttl
+		s.maxResults = maxResults
+	}
+}
+
+func WithJanitorInterval(d time.Duration) Option {
+	return func(s *Service) {
+		if d > 0 {
+			s.janitorInterval = d
+		}
+	}
+}
+
+func WithMetrics(m Metrics) Option {
+	return func(s *Service) {
+		if m != nil {
+			s.metrics = m
+		}
+	}
+}
+
+func WithEgressPolicy(p *policy.Policy) Option {
+	return func(s *Service) {
+		s.policy = p
+	}
+}
+
+// WithClientQuota limits how many unfinished tasks a single authenticated
+// client may have. Zero disables the limit.
+func WithClientQuota(n int) Option {
+	return func(s *Service) {
+		s.clientQuota = n
+	}
+}
+
+// WithHostLimits bounds concurrency and request rate per destination host.
+// Overrides are keyed by host name and replace the default limit for it.
+func WithHostLimits(def HostLimit, overrides map[string]HostLimit) Option {
+	return func(s *Service) {
+		s.hostLimit = def
+		s.hostOverrides = overrides
+	}
+}
+
+func WithTransport(cfg TransportConfig) Option {
+	return func(s *Service) {
+		s.transportConfig = cfg
+	}
+}
@@ -0,0 +1,47 @@
+package service
+
+import "sync"
+
+// quota limits the number of unfinished tasks per client. Tasks submitted
+// without a client, i.e. with authentication disabled, are not counted.
+type quota struct {
+	limit int
+
+	mu       sync.Mutex
+	inFlight map[string]int
+}
+
+func newQuota(limit int) *quota {
+	return &quota{limit: limit, inFlight: make(map[string]int)}
+}
+
+func (q *quota) acquire(client string) bool {
+	if q.limit <= 0 || client == "" {
+		return true
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.inFlight[client] >= q.limit {
+		return false
+	}
+	q.inFlight[client]++
+
+	return true
+}
+
+func (q *quota) release(client string) {
+	if q.limit <= 0 || client == "" {
+		return
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.inFlight[client] <= 1 {
+		delete(q.inFlight, client)
+		return
+	}
+	q.inFlight[client]--
+}
@@ -0,0 +1,74 @@
+package service
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/Mi7teR/aggregator/internal/policy"
+	"github.com/Mi7teR/aggregator/internal/task/entity"
+)
+
+// redirectChain applies the redirect policy of a task to a single attempt
+// and records every redirect response it sees, including the one it stops
+// at.
+type redirectChain struct {
+	noFollow     bool
+	maxHops      int
+	sameHostOnly bool
+	hops         []entity.TaskRedirect
+}
+
+func newRedirectChain(p *entity.TaskRedirectPolicy) *redirectChain {
+	c := &redirectChain{maxHops: maxRedirects}
+	if p == nil {
+		return c
+	}
+
+	c.noFollow = p.NoFollow
+	c.sameHostOnly = p.SameHostOnly
+	if p.MaxHops > 0 {
+		c.maxHops = p.MaxHops
+	}
+
+	return c
+}
+
+// check returns the CheckRedirect func of the attempt. A redirect that is
+// not followed, or leaves the original host when same-host-only is set,
+// ends the attempt with the redirect response itself. Running out of hops
+// fails the attempt.
+func (c *redirectChain) check(p *policy.Policy) func(req *http.Request, via []*http.Request) error {
+	return func(req *http.Request, via []*http.Request) error {
+		hop := entity.TaskRedirect{URL: via[len(via)-1].URL.String()}
+		if req.Response != nil {
+			hop.StatusCode = req.Response.StatusCode
+			hop.Location = req.Response.Header.Get("Location")
+		}
+		c.hops = append(c.hops, hop)
+
+		if c.noFollow {
+			return http.ErrUseLastResponse
+		}
+
+		if c.sameHostOnly && !strings.EqualFold(req.URL.Hostname(), via[0].URL.Hostname()) {
+			return http.ErrUseLastResponse
+		}
+
+		if len(via) > c.maxHops {
+			return fmt.Errorf("%w: stopped after %d", ErrTooManyRedirects, c.maxHops)
+		}
+
+		if p == nil {
+			return nil
+		}
+
+		return p.CheckURL(req.URL)
+	}
+}
+
+func withRedirects(result *entity.TaskResult, c *redirectChain) *entity.TaskResult {
+	result.Redirects = c.hops
+
+	return result
+}
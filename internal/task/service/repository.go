@@ -2,6 +2,7 @@ package service
 
 import (
 	"context"
+	"time"
 
 	"github.com/Mi7teR/aggregator/internal/task/entity"
 )
@@ -10,4 +11,7 @@ type Repository interface {
 	Create(ctx context.Context, task *entity.Task) (string, error)
 	GetByID(ctx context.Context, id string) (*entity.TaskResult, error)
 	Update(ctx context.Context, res *entity.TaskResult) error
+	List(ctx context.Context, filter *entity.TaskFilter) (*entity.TaskList, error)
+	Delete(ctx context.Context, id string) error
+	Evict(ctx context.Context, finishedBefore time.Time, maxResults int) (int, error)
 }
@@ -0,0 +1,170 @@
+package service
+
+import (
+	"context"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Mi7teR/aggregator/internal/task/entity"
+)
+
+const (
+	defaultBackoffBase = 500 * time.Millisecond
+	defaultBackoffMax  = 30 * time.Second
+	maxRetryAttempts   = 10
+	maxDrainSize       = 64 << 10
+)
+
+type retryPolicy struct {
+	maxAttempts       int
+	base              time.Duration
+	max               time.Duration
+	jitter            bool
+	statusCodes       []int
+	respectRetryAfter bool
+}
+
+func newRetryPolicy(r *entity.TaskRetry) *retryPolicy {
+	p := &retryPolicy{
+		maxAttempts: 1,
+		base:        defaultBackoffBase,
+		max:         defaultBackoffMax,
+		statusCodes: []int{
+			http.StatusTooManyRequests,
+			http.StatusBadGateway,
+			http.StatusServiceUnavailable,
+			http.StatusGatewayTimeout,
+		},
+	}
+
+	if r == nil {
+		return p
+	}
+
+	if r.MaxAttempts > 0 {
+		p.maxAttempts = r.MaxAttempts
+	}
+	if p.maxAttempts > maxRetryAttempts {
+		p.maxAttempts = maxRetryAttempts
+	}
+	if r.BackoffBase > 0 {
+		p.base = time.Duration(r.BackoffBase)
+	}
+	if r.BackoffMax > 0 {
+		p.max = time.Duration(r.BackoffMax)
+	}
+	if len(r.RetryStatusCodes) > 0 {
+		p.statusCodes = r.RetryStatusCodes
+	}
+
+	p.jitter = r.Jitter
+	p.respectRetryAfter = r.RespectRetryAfter
+
+	return p
+}
+
+func (p *retryPolicy) retryableStatus(code int) bool {
+	for _, c := range p.statusCodes {
+		if c == code {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (p *retryPolicy) retryableError(err error) bool {
+	switch classifyError(err) {
+	case entity.ErrorKindTimeout, entity.ErrorKindDNS, entity.ErrorKindConnectionRefused, entity.ErrorKindUnknown:
+		return true
+	case entity.ErrorKindTLS, entity.ErrorKindInvalidRequest, entity.ErrorKindCancelled,
+		entity.ErrorKindDestinationNotAllowed:
+		return false
+	}
+
+	return false
+}
+
+// delay returns the backoff before the attempt following the given one,
+// preferring the upstream Retry-After header when the policy respects it.
+func (p *retryPolicy) delay(attempt int, header http.Header) time.Duration {
+	if p.respectRetryAfter && header != nil {
+		if d, ok := parseRetryAfter(header.Get("Retry-After"), time.Now()); ok {
+			return d
+		}
+	}
+
+	d := p.max
+	if shift := attempt - 1; shift < 63 && p.base<<shift > 0 && p.base<<shift < p.max {
+		d = p.base << shift
+	}
+
+	if p.jitter {
+		d = time.Duration(rand.Int63n(int64(d) + 1)) //nolint:gosec // jitter does not need crypto rand
+	}
+
+	return d
+}
+
+func parseRetryAfter(v string, now time.Time) (time.Duration, bool) {
+	if v == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(v); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	at, err := http.ParseTime(v)
+	if err != nil {
+		return 0, false
+	}
+
+	if d := at.Sub(now); d > 0 {
+		return d, true
+	}
+
+	return 0, true
+}
+
+func fitsDeadline(ctx context.Context, d time.Duration) bool {
+	deadline, ok := ctx.Deadline()
+
+	return !ok || time.Until(deadline) > d
+}
+
+// backoff waits before the next attempt and records the delay on the last
+// attempt. It reports false when the task deadline would pass or ctx ends.
+func backoff(ctx context.Context, d time.Duration, attempts []entity.TaskAttempt) bool {
+	if !fitsDeadline(ctx, d) {
+		return false
+	}
+
+	attempts[len(attempts)-1].Backoff = entity.Duration(d)
+
+	return sleep(ctx, d)
+}
+
+func sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func drain(res *http.Response) {
+	_, _ = io.Copy(io.Discard, io.LimitReader(res.Body, maxDrainSize))
+	_ = res.Body.Close()
+}
@@ -1,99 +1,778 @@
 package service
 
 import (
+	"bytes"
 	"context"
-	"log"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
 	"net/http"
+	"net/url"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/Mi7teR/aggregator/internal/auth"
+	"github.com/Mi7teR/aggregator/internal/logger"
+	"github.com/Mi7teR/aggregator/internal/policy"
 	"github.com/Mi7teR/aggregator/internal/task/entity"
 )
 
+const (
+	DefaultMaxBodySize = 1 << 20
+	DefaultWorkers     = 64
+	DefaultQueueSize   = 1024
+)
+
+var (
+	ErrBodyNotCaptured = errors.New("response body was not captured")
+	ErrQueueFull       = errors.New("task queue is full")
+	ErrShutdown        = errors.New("service is shutting down")
+	ErrTaskFinished    = errors.New("task already finished")
+	ErrTaskNotFinished = errors.New("task not finished yet")
+	ErrTaskNotFound    = errors.New("task result not found")
+	ErrQuotaExceeded   = errors.New("too many unfinished tasks")
+	ErrCallbackPending = errors.New("callback delivery still pending")
+)
+
 type Service struct {
-	repo    Repository
-	timeout time.Duration
+	repo        Repository
+	timeout     time.Duration
+	maxBodySize int64
+	maxTimeout  time.Duration
+	workers     int
+	queueSize   int
+
+	resultTTL       time.Duration
+	maxResults      int
+	janitorInterval time.Duration
+
+	clientQuota   int
+	hostLimit     HostLimit
+	hostOverrides map[string]HostLimit
+	hosts         *hostLimiter
+
+	mu      sync.RWMutex
+	closed  bool
+	queue   chan job
+	ready   chan job
+	slots   chan struct{}
+	stopCtx context.Context
+	stop    context.CancelFunc
+	wg      sync.WaitGroup
+
+	execMu     sync.Mutex
+	executions map[string]*execution
+	aborted    bool
+	quota      *quota
+	broker     *broker
+	inFlight   atomic.Int64
+	metrics    Metrics
+
+	policy          *policy.Policy
+	transportConfig TransportConfig
+	transportStats  transportStats
+	transport       *http.Transport
+
+	callbackClient   *http.Client
+	callbackAttempts int
+	callbacks        sync.WaitGroup
+	callbackCtx      context.Context
+	cancelCallbacks  context.CancelFunc
+}
+
+type job struct {
+	id        string
+	task      *entity.Task
+	requestID string
+	progress  *progress
+}
+
+// progress carries a started task between its attempts. On the worker pool
+// the attempts may run on different workers, as the task is parked whenever
+// its host has no capacity for the next one.
+type progress struct {
+	ctx       context.Context
+	cancel    context.CancelFunc
+	startedAt time.Time
+	policy    *retryPolicy
+	attempts  []entity.TaskAttempt
+	pooled    bool
+}
+
+func NewService(repo Repository, timeout time.Duration, opts ...Option) *Service {
+	s := &Service{
+		repo:        repo,
+		timeout:     timeout,
+		maxBodySize: DefaultMaxBodySize,
+		workers:     DefaultWorkers,
+		queueSize:   DefaultQueueSize,
+		executions:  make(map[string]*execution),
+		broker:      newBroker(),
+		metrics:     nopMetrics{},
+
+		janitorInterval: DefaultJanitorInterval,
+
+		callbackAttempts: DefaultCallbackAttempts,
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	if s.maxTimeout <= 0 {
+		s.maxTimeout = timeout
+	}
+
+	s.quota = newQuota(s.clientQuota)
+	s.hosts = newHostLimiter(s.hostLimit, s.hostOverrides)
+	s.transport = newTransport(s.policy, s.transportConfig, &s.transportStats)
+	s.callbackClient = &http.Client{Transport: s.transport, CheckRedirect: s.checkRedirect}
+	s.callbackCtx, s.cancelCallbacks = context.WithCancel(context.Background())
+
+	s.queue = make(chan job, s.queueSize)
+	s.ready = make(chan job)
+	s.stopCtx, s.stop = context.WithCancel(context.Background())
+	s.slots = make(chan struct{}, s.queueSize)
+
+	s.wg.Add(s.workers)
+	for i := 0; i < s.workers; i++ {
+		go s.work()
+	}
+
+	if s.retentionEnabled() {
+		s.wg.Add(1)
+		go s.janitor()
+	}
+
+	return s
 }
 
-func NewService(repo Repository, timeout time.Duration) *Service {
-	return &Service{repo: repo, timeout: timeout}
+// Shutdown stops accepting tasks and fails the ones still queued. Running
+// tasks and callback deliveries may finish until ctx is done, then they are
+// cancelled.
+func (s *Service) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	if !s.closed {
+		s.closed = true
+		close(s.queue)
+		s.stop()
+	}
+	s.mu.Unlock()
+
+	s.CloseEvents()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		s.callbacks.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		s.transport.CloseIdleConnections()
+		return nil
+	case <-ctx.Done():
+	}
+
+	s.cancelExecutions()
+	s.cancelCallbacks()
+	<-done
+	s.transport.CloseIdleConnections()
+
+	return ctx.Err()
 }
 
 func (s *Service) GetTaskResult(ctx context.Context, id string) (*entity.TaskResult, error) {
+	res, err := s.get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// get loads the task result on behalf of the client in ctx. Tasks owned by
+// another client are reported as not found, so their ids are not leaked.
+func (s *Service) get(ctx context.Context, id string) (*entity.TaskResult, error) {
 	res, err := s.repo.GetByID(ctx, id)
 	if err != nil {
 		return nil, err
 	}
 
+	if client := auth.ClientID(ctx); client != "" && res.Owner != client {
+		return nil, ErrTaskNotFound
+	}
+
+	return res, nil
+}
+
+// WaitTaskResult returns the task result once it reaches a terminal status
+// or the wait expires, whichever comes first.
+func (s *Service) WaitTaskResult(ctx context.Context, id string, wait time.Duration) (*entity.TaskResult, error) {
+	updates, unsubscribe := s.broker.subscribe(matchID(id))
+	defer unsubscribe()
+
+	res, err := s.get(ctx, id)
+	if err != nil || res.Status.Terminal() {
+		return res, err
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	for {
+		select {
+		case update, ok := <-updates:
+			if !ok || update.Status.Terminal() {
+				return s.repo.GetByID(ctx, id)
+			}
+		case <-timer.C:
+			return s.repo.GetByID(ctx, id)
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// Subscribe streams status changes of tasks matching the filter until the
+// returned func is called or the service shuts down. Authenticated clients
+// only see their own tasks.
+func (s *Service) Subscribe(ctx context.Context, filter *entity.EventFilter) (<-chan entity.TaskResult, func()) {
+	filter.Owner = auth.ClientID(ctx)
+	return s.broker.subscribe(filter.Match)
+}
+
+// WatchTask returns the current task result together with a stream of its
+// subsequent status changes.
+func (s *Service) WatchTask(
+	ctx context.Context,
+	id string,
+) (*entity.TaskResult, <-chan entity.TaskResult, func(), error) {
+	updates, unsubscribe := s.broker.subscribe(matchID(id))
+
+	res, err := s.get(ctx, id)
+	if err != nil {
+		unsubscribe()
+		return nil, nil, nil, err
+	}
+
+	return res, updates, unsubscribe, nil
+}
+
+// CloseEvents ends all event subscriptions, letting streaming requests
+// finish before the server shuts down.
+func (s *Service) CloseEvents() {
+	s.broker.close()
+}
+
+func (s *Service) ListTasks(ctx context.Context, filter *entity.TaskFilter) (*entity.TaskList, error) {
+	filter.Owner = auth.ClientID(ctx)
+	return s.repo.List(ctx, filter)
+}
+
+func (s *Service) GetTaskBody(ctx context.Context, id string) (*entity.TaskResult, error) {
+	res, err := s.get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if !res.BodyCaptured {
+		return nil, ErrBodyNotCaptured
+	}
+
+	return res, nil
+}
+
+func (s *Service) CancelTask(ctx context.Context, id string) (*entity.TaskResult, error) {
+	s.execMu.Lock()
+	defer s.execMu.Unlock()
+
+	res, err := s.get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.Status.Terminal() {
+		return res, ErrTaskFinished
+	}
+
+	finishedAt := time.Now().UTC()
+	res = &entity.TaskResult{
+		ID:         id,
+		Status:     entity.TaskStatusCancelled,
+		StartedAt:  res.StartedAt,
+		FinishedAt: &finishedAt,
+		Error:      errTaskCancelled.Error(),
+		ErrorKind:  entity.ErrorKindCancelled,
+		Owner:      res.Owner,
+	}
+
+	e, ok := s.executions[id]
+	if ok {
+		e.cancelled = true
+		if e.cancel != nil {
+			e.cancel()
+		}
+		s.quota.release(e.task.Owner)
+
+		if hasCallback(e.task) {
+			res.Callback = &entity.CallbackDelivery{Status: entity.CallbackStatusPending}
+		}
+	}
+
+	if err = s.repo.Update(ctx, res); err != nil {
+		return nil, err
+	}
+
+	slog.InfoContext(ctx, "task cancelled")
+	s.broker.publish(res)
+	s.metrics.TaskFinished(res.Status)
+
+	if ok {
+		s.notify(ctx, e.task, res)
+	}
+
+	return res, nil
+}
+
+// DeleteTask removes the result of a finished task. Unfinished tasks have
+// to be cancelled first, and results are kept until their callback delivery
+// is done with.
+func (s *Service) DeleteTask(ctx context.Context, id string) (*entity.TaskResult, error) {
+	s.execMu.Lock()
+	defer s.execMu.Unlock()
+
+	res, err := s.get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if !res.Status.Terminal() {
+		return res, ErrTaskNotFinished
+	}
+
+	if res.Callback != nil && res.Callback.Status == entity.CallbackStatusPending {
+		return res, ErrCallbackPending
+	}
+
+	if err = s.repo.Delete(ctx, id); err != nil {
+		return nil, err
+	}
+
 	return res, nil
 }
 
 func (s *Service) AddTask(ctx context.Context, task *entity.Task) (string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.closed {
+		return "", ErrShutdown
+	}
+
+	if err := task.Validate(); err != nil {
+		return "", err
+	}
+
+	task.Owner = auth.ClientID(ctx)
+
+	if err := s.checkDestinations(task); err != nil {
+		return "", err
+	}
+
+	if !s.quota.acquire(task.Owner) {
+		return "", ErrQuotaExceeded
+	}
+
+	select {
+	case s.slots <- struct{}{}:
+	default:
+		s.quota.release(task.Owner)
+		return "", ErrQueueFull
+	}
+
 	taskID, err := s.repo.Create(ctx, task)
 	if err != nil {
+		<-s.slots
+		s.quota.release(task.Owner)
 		return "", err
 	}
 
-	go s.Execute(taskID, task)
+	s.track(taskID, task)
+	s.metrics.TaskCreated(task.Method)
+	s.broker.publish(&entity.TaskResult{ID: taskID, Status: entity.TaskStatusNew, Owner: task.Owner})
+	slog.DebugContext(logger.WithTaskID(ctx, taskID), "task queued", "method", task.Method.String())
+	s.queue <- job{id: taskID, task: task, requestID: logger.RequestID(ctx)}
 
 	return taskID, nil
 }
 
-func (s *Service) Execute(id string, task *entity.Task) {
-	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
-	defer cancel()
-
-	err := s.repo.Update(ctx, &entity.TaskResult{
-		ID:     id,
-		Status: entity.TaskStatusInProcess,
-	})
+// RunTask adds the task and waits up to its timeout for the final result.
+// The result is not terminal yet when the deadline passes first.
+func (s *Service) RunTask(ctx context.Context, task *entity.Task) (*entity.TaskResult, error) {
+	id, err := s.AddTask(ctx, task)
 	if err != nil {
-		log.Println(err)
+		return nil, err
+	}
+
+	return s.WaitTaskResult(ctx, id, s.totalTimeout(task))
+}
+
+func (s *Service) work() {
+	defer s.wg.Done()
+
+	for {
+		select {
+		case j := <-s.ready:
+			s.run(j)
+		case j, ok := <-s.queue:
+			if !ok {
+				return
+			}
+
+			s.run(j)
+		}
+	}
+}
+
+// run starts a job that left the queue, or continues a started one, once
+// its host has capacity. Otherwise the job is parked. Queued jobs are failed
+// when the service is stopping.
+func (s *Service) run(j job) {
+	if j.progress != nil {
+		s.proceed(j, nil)
 		return
 	}
 
-	req, err := http.NewRequestWithContext(ctx, task.Method.String(), task.URL, nil)
-	if err != nil {
-		errUpdate := s.repo.Update(ctx, &entity.TaskResult{
-			ID:     id,
-			Status: entity.TaskStatusError,
-		})
+	ctx := logger.WithTaskID(logger.WithRequestID(context.Background(), j.requestID), j.id)
+
+	if s.stopping() {
+		<-s.slots
+		s.abandon(ctx, j.id, j.task)
+		return
+	}
+
+	releaseHost, granted := s.hosts.tryAcquire(taskHost(j.task))
+	if !granted {
+		s.park(j)
+		return
+	}
+
+	<-s.slots
+	if j.progress = s.start(ctx, j.id, j.task, true); j.progress == nil {
+		releaseHost()
+		return
+	}
+
+	s.proceed(j, releaseHost)
+}
+
+// proceed runs the attempts of a started job and stores its result, or
+// parks the job when the next attempt has to wait for the host.
+func (s *Service) proceed(j job, releaseHost func()) {
+	res := s.do(j.id, j.task, j.progress, releaseHost)
+	if res == nil {
+		s.park(j)
+		return
+	}
+
+	s.end(j.id, j.task, j.progress, res)
+}
+
+// park waits outside of the worker pool until the host of a job has
+// capacity, so a saturated host does not hold up tasks to other hosts.
+// Nothing is reserved while waiting: the worker picking the job up takes
+// the capacity, and parks the job again when it is gone. A queued job keeps
+// its queue slot until then.
+func (s *Service) park(j job) {
+	var (
+		ctx    context.Context
+		cancel = func() {}
+	)
+	if j.progress != nil {
+		ctx = j.progress.ctx
+	} else {
+		ctx, cancel = context.WithCancel(s.stopCtx)
+		s.parked(j.id, cancel)
+	}
 
-		if errUpdate != nil {
-			log.Println(err)
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer cancel()
+
+		if err := s.hosts.wait(ctx, taskHost(j.task)); err != nil {
+			s.expire(j, err)
 			return
 		}
+
+		select {
+		case s.ready <- j:
+		case <-ctx.Done():
+			s.expire(j, ctx.Err())
+		case <-s.stopCtx.Done():
+			// The workers stop with the queue, so a started task makes
+			// its remaining attempts here.
+			if j.progress == nil {
+				s.expire(j, ErrShutdown)
+				return
+			}
+
+			s.proceed(j, nil)
+		}
+	}()
+}
+
+// expire ends a parked job whose wait was cut short.
+func (s *Service) expire(j job, err error) {
+	if j.progress == nil {
+		ctx := logger.WithTaskID(logger.WithRequestID(context.Background(), j.requestID), j.id)
+
+		<-s.slots
+		s.abandon(ctx, j.id, j.task)
+		return
+	}
+
+	s.end(j.id, j.task, j.progress, withAttempts(errorResult(j.id, err), j.progress.attempts))
+}
+
+func (s *Service) stopping() bool {
+	select {
+	case <-s.stopCtx.Done():
+		return true
+	default:
+		return false
+	}
+}
+
+func (s *Service) Execute(id string, task *entity.Task) {
+	if p := s.start(logger.WithTaskID(context.Background(), id), id, task, false); p != nil {
+		s.end(id, task, p, s.do(id, task, p, nil))
+	}
+}
+
+// start marks the task as in process. Its total timeout runs from here, so
+// waiting for the host before the first attempt does not count towards it.
+// Pooled tasks are parked instead of waiting for the host of a retry.
+func (s *Service) start(ctx context.Context, id string, task *entity.Task, pooled bool) *progress {
+	ctx, cancel := context.WithTimeout(ctx, s.totalTimeout(task))
+
+	startedAt := time.Now().UTC()
+	if !s.begin(ctx, id, task, cancel, &startedAt) {
+		cancel()
+		return nil
+	}
+
+	s.inFlight.Add(1)
+	policy := newRetryPolicy(task.Retry)
+
+	return &progress{
+		ctx:       ctx,
+		cancel:    cancel,
+		startedAt: startedAt,
+		policy:    policy,
+		attempts:  make([]entity.TaskAttempt, 0, policy.maxAttempts),
+		pooled:    pooled,
+	}
+}
+
+// end stores the final result of a started task.
+func (s *Service) end(id string, task *entity.Task, p *progress, res *entity.TaskResult) {
+	defer p.cancel()
+	s.inFlight.Add(-1)
+
+	res.StartedAt = &p.startedAt
+	res.Owner = task.Owner
+	s.finish(context.WithoutCancel(p.ctx), id, res)
+}
+
+// do makes the remaining attempts of a started task. releaseHost frees the
+// host capacity granted for the next attempt. Without it the attempt waits
+// for the host, except for pooled tasks: do returns nil for them when the
+// host has no capacity, so the job can be parked.
+func (s *Service) do(id string, task *entity.Task, p *progress, releaseHost func()) *entity.TaskResult {
+	ctx := p.ctx
+
+	for n := len(p.attempts) + 1; ; n++ {
+		if releaseHost == nil && p.pooled {
+			var granted bool
+			if releaseHost, granted = s.hosts.tryAcquire(taskHost(task)); !granted {
+				return nil
+			}
+		}
+
+		trace := newTimingTrace()
+		redirects := newRedirectChain(task.Redirect)
+
+		res, err := s.send(ctx, task, trace, redirects, releaseHost)
+		releaseHost = nil
+		s.observeUpstream(task, res, trace)
+		if err != nil {
+			slog.WarnContext(ctx, "upstream request failed", "attempt", n, "error", err)
+			p.attempts = append(p.attempts, entity.TaskAttempt{Error: err.Error(), ErrorKind: classifyError(err)})
+
+			if n < p.policy.maxAttempts && p.policy.retryableError(err) &&
+				backoff(ctx, p.policy.delay(n, nil), p.attempts) {
+				continue
+			}
+
+			return withRedirects(withTiming(withAttempts(errorResult(id, err), p.attempts), trace), redirects)
+		}
+
+		p.attempts = append(p.attempts, entity.TaskAttempt{HTTPStatusCode: res.StatusCode})
+
+		if n < p.policy.maxAttempts && p.policy.retryableStatus(res.StatusCode) {
+			if delay := p.policy.delay(n, res.Header); fitsDeadline(ctx, delay) {
+				drain(res)
+
+				if backoff(ctx, delay, p.attempts) {
+					continue
+				}
+
+				return withRedirects(withTiming(withAttempts(errorResult(id, ctx.Err()), p.attempts), trace), redirects)
+			}
+		}
+
+		return withRedirects(withTiming(withAttempts(s.complete(ctx, id, task, res), p.attempts), trace), redirects)
+	}
+}
+
+// send performs one attempt. The host capacity for it is either granted
+// already, in which case releaseHost frees it, or waited for here.
+func (s *Service) send(
+	ctx context.Context, task *entity.Task, trace *timingTrace, redirects *redirectChain, releaseHost func(),
+) (*http.Response, error) {
+	ctx, releasePhases := withPhaseTimeouts(s.transportStats.withContext(trace.withContext(ctx)), s.phaseTimeouts(task))
+	release := func() {
+		releasePhases()
+		trace.finish()
+	}
+
+	req, err := newRequest(ctx, task)
+	if err != nil {
+		if releaseHost != nil {
+			releaseHost()
+		}
+		release()
+		return nil, &invalidRequestError{err: err}
 	}
 
 	for i := range task.Headers {
 		req.Header.Add(i, task.Headers[i])
 	}
 
-	client := &http.Client{}
+	if releaseHost == nil {
+		releaseHost, err = s.hosts.acquire(ctx, req.URL.Hostname())
+		if err != nil {
+			release()
+			return nil, err
+		}
+	}
+
+	client := &http.Client{Transport: s.transport, CheckRedirect: redirects.check(s.policy)}
 
 	res, err := client.Do(req)
 	if err != nil {
-		errUpdate := s.repo.Update(ctx, &entity.TaskResult{
-			ID:     id,
-			Status: entity.TaskStatusError,
-		})
-
-		if errUpdate != nil {
-			log.Println(err)
+		var timeoutErr *phaseTimeoutError
+		if errors.As(context.Cause(ctx), &timeoutErr) {
+			err = &url.Error{Op: req.Method, URL: req.URL.String(), Err: timeoutErr}
 		}
-		return
+
+		releaseHost()
+		release()
+		return nil, err
 	}
 
+	res.Body = &closeHookBody{ReadCloser: res.Body, hook: func() {
+		releaseHost()
+		release()
+	}}
+
+	return res, nil
+}
+
+func (s *Service) complete(ctx context.Context, id string, task *entity.Task, res *http.Response) *entity.TaskResult {
 	defer res.Body.Close()
 
-	err = s.repo.Update(ctx, &entity.TaskResult{
+	result := &entity.TaskResult{
 		ID:             id,
 		Status:         entity.TaskStatusDone,
 		HTTPStatusCode: res.StatusCode,
 		Headers:        res.Header,
 		Length:         res.ContentLength,
-	})
+	}
+
+	if task.CaptureBody {
+		if err := s.captureBody(res.Body, result); err != nil {
+			slog.WarnContext(ctx, "capture response body", "error", err)
+			result.Status = entity.TaskStatusError
+			result.Error = err.Error()
+			result.ErrorKind = classifyError(err)
+		}
+	}
+
+	return result
+}
+
+func (s *Service) observeUpstream(task *entity.Task, res *http.Response, trace *timingTrace) {
+	var statusCode int
+	if res != nil {
+		statusCode = res.StatusCode
+	}
+
+	s.metrics.UpstreamRequest(task.Method, statusCode, time.Since(trace.start))
+}
+
+func withAttempts(result *entity.TaskResult, attempts []entity.TaskAttempt) *entity.TaskResult {
+	result.AttemptCount = len(attempts)
+	result.Attempts = attempts
+
+	return result
+}
+
+func newRequest(ctx context.Context, task *entity.Task) (*http.Request, error) {
+	payload, err := task.Payload()
 	if err != nil {
-		log.Println(err)
-		return
+		return nil, err
 	}
+
+	var body io.Reader
+	if len(payload) > 0 {
+		body = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, task.Method.String(), task.URL, body)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedScheme, req.URL.Scheme)
+	}
+
+	if req.URL.Host == "" {
+		return nil, ErrMissingHost
+	}
+
+	return req, nil
+}
+
+func (s *Service) captureBody(body io.Reader, result *entity.TaskResult) error {
+	data, err := io.ReadAll(io.LimitReader(body, s.maxBodySize+1))
+	if err != nil {
+		return err
+	}
+
+	if int64(len(data)) > s.maxBodySize {
+		data = data[:s.maxBodySize]
+		result.BodyTruncated = true
+	}
+
+	result.Body = data
+	result.BodyCaptured = true
+
+	return nil
 }
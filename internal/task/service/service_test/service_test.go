@@ -2,14 +2,24 @@ package service_test
 
 import (
 	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"net/netip"
+	"net/url"
 	"reflect"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/Mi7teR/aggregator/internal/auth"
+	"github.com/Mi7teR/aggregator/internal/policy"
 	"github.com/Mi7teR/aggregator/internal/task/entity"
 	"github.com/Mi7teR/aggregator/internal/task/repository"
 	"github.com/Mi7teR/aggregator/internal/task/service"
@@ -20,11 +30,11 @@ func TestNewService(t *testing.T) {
 	type args struct {
 		repo    service.Repository
 		timeout time.Duration
+		opts    []service.Option
 	}
 	tests := []struct {
 		name string
 		args args
-		want *service.Service
 	}{
 		{
 			"create new service",
@@ -32,13 +42,24 @@ func TestNewService(t *testing.T) {
 				repo:    repository.NewTaskInMemoryRepository(),
 				timeout: time.Second * 30,
 			},
-			service.NewService(repository.NewTaskInMemoryRepository(), time.Second*30),
+		},
+		{
+			"create new service with worker pool options",
+			args{
+				repo:    repository.NewTaskInMemoryRepository(),
+				timeout: time.Second * 30,
+				opts:    []service.Option{service.WithWorkers(2), service.WithQueueSize(10)},
+			},
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := service.NewService(tt.args.repo, tt.args.timeout); !reflect.DeepEqual(got, tt.want) {
-				t.Errorf("NewService() = %v, want %v", got, tt.want)
+			got := service.NewService(tt.args.repo, tt.args.timeout, tt.args.opts...)
+			if got == nil {
+				t.Fatalf("NewService() = nil")
+			}
+			if err := got.Shutdown(context.Background()); err != nil {
+				t.Errorf("Shutdown() error = %v", err)
 			}
 		})
 	}
@@ -102,6 +123,11 @@ func TestService_GetTaskResult(t *testing.T) {
 		t.Errorf("Expected to parse task id without errors, got: %s", err)
 	}
 
+	created, err := repo.GetByID(context.Background(), id)
+	if err != nil {
+		t.Errorf("Expected to get created task result, got %s", err)
+	}
+
 	type fields struct {
 		repo    service.Repository
 		timeout time.Duration
@@ -130,6 +156,7 @@ func TestService_GetTaskResult(t *testing.T) {
 			&entity.TaskResult{
 				ID:             id,
 				Status:         entity.TaskStatusNew,
+				CreatedAt:      created.CreatedAt,
 				HTTPStatusCode: 0,
 				Headers:        nil,
 				Length:         0,
@@ -204,6 +231,11 @@ func TestService_Execute(t *testing.T) {
 		t.Errorf("Expected to parse task id without errors, got: %s", err)
 	}
 
+	created, err := repo.GetByID(context.Background(), id)
+	if err != nil {
+		t.Errorf("expected to get created task result, got %s", err)
+	}
+
 	s := service.NewService(repo, timeout)
 
 	s.Execute(id, task)
@@ -212,12 +244,23 @@ func TestService_Execute(t *testing.T) {
 
 	res, err := repo.GetByID(context.Background(), id)
 	if err != nil {
-		t.Errorf("expected to get task result, got %s", err)
+		t.Fatalf("expected to get task result, got %s", err)
+	}
+
+	if res.StartedAt == nil || res.FinishedAt == nil || res.FinishedAt.Before(*res.StartedAt) {
+		t.Errorf("Execute() got started at %v, finished at %v", res.StartedAt, res.FinishedAt)
+	}
+	if res.Timing == nil || res.Timing.Connect <= 0 || res.Timing.TimeToFirstByte <= 0 ||
+		res.Timing.Total < res.Timing.TimeToFirstByte {
+		t.Errorf("Execute() got timing %+v", res.Timing)
 	}
 
 	taskResult := &entity.TaskResult{
 		ID:             id,
 		Status:         entity.TaskStatusDone,
+		CreatedAt:      created.CreatedAt,
+		StartedAt:      res.StartedAt,
+		FinishedAt:     res.FinishedAt,
 		HTTPStatusCode: http.StatusOK,
 		Headers: map[string][]string{
 			"Content-Length": {
@@ -230,9 +273,1397 @@ func TestService_Execute(t *testing.T) {
 				time.Now().UTC().Format(http.TimeFormat),
 			},
 		},
-		Length: 10,
+		Length:       10,
+		AttemptCount: 1,
+		Attempts:     []entity.TaskAttempt{{HTTPStatusCode: http.StatusOK}},
+		Timing:       res.Timing,
 	}
 	if !reflect.DeepEqual(res, taskResult) {
 		t.Errorf("Execute() got = %v, want %v", res, taskResult)
 	}
 }
+
+func TestService_ExecuteTimingRedirect(t *testing.T) {
+	const delay = 200 * time.Millisecond
+
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer target.Close()
+
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(delay)
+		http.Redirect(w, r, target.URL, http.StatusFound)
+	}))
+	defer origin.Close()
+
+	repo := repository.NewTaskInMemoryRepository()
+	s := service.NewService(repo, time.Second*30)
+
+	task := &entity.Task{Method: entity.MethodGet, URL: origin.URL}
+	id, err := repo.Create(context.Background(), task)
+	if err != nil {
+		t.Fatalf("Expected to create new task result, got %s", err)
+	}
+
+	s.Execute(id, task)
+
+	res, err := repo.GetByID(context.Background(), id)
+	if err != nil {
+		t.Fatalf("expected to get task result, got %s", err)
+	}
+	if res.Timing == nil || res.Timing.Connect <= 0 || time.Duration(res.Timing.TimeToFirstByte) >= delay ||
+		time.Duration(res.Timing.Total) < delay {
+		t.Errorf("Execute() got timing %+v, want phases of the final request only", res.Timing)
+	}
+}
+
+func TestService_ExecuteWithBody(t *testing.T) {
+	repo := repository.NewTaskInMemoryRepository()
+	timeout := time.Second * 1000
+
+	wg := &sync.WaitGroup{}
+	wg.Add(1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer wg.Done()
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("Expected to read request body, got: %s", err)
+		}
+		if string(body) != `{"key":"value"}` {
+			t.Errorf("Expected request body {\"key\":\"value\"}, got: %s", body)
+		}
+		if r.ContentLength != int64(len(body)) {
+			t.Errorf("Expected Content-Length %d, got: %d", len(body), r.ContentLength)
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	task := &entity.Task{
+		Method: entity.MethodPut,
+		URL:    server.URL,
+		Body:   entity.TaskBody(`{"key":"value"}`),
+	}
+
+	id, err := repo.Create(context.Background(), task)
+	if err != nil {
+		t.Errorf("Expected to create new task result, got %s", err)
+	}
+
+	s := service.NewService(repo, timeout)
+
+	s.Execute(id, task)
+
+	wg.Wait()
+
+	res, err := repo.GetByID(context.Background(), id)
+	if err != nil {
+		t.Errorf("expected to get task result, got %s", err)
+	}
+
+	if res.Status != entity.TaskStatusDone || res.HTTPStatusCode != http.StatusCreated {
+		t.Errorf("Execute() got status %v with code %d, want done with %d", res.Status, res.HTTPStatusCode, http.StatusCreated)
+	}
+}
+
+func TestService_ExecuteCaptureBody(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"key":"value"}`)) //nolint:errcheck // we dont test it :)
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name          string
+		maxBodySize   int64
+		captureBody   bool
+		wantBody      []byte
+		wantTruncated bool
+		wantErr       bool
+	}{
+		{
+			name:        "capture full body",
+			maxBodySize: service.DefaultMaxBodySize,
+			captureBody: true,
+			wantBody:    []byte(`{"key":"value"}`),
+		},
+		{
+			name:          "capture truncated body",
+			maxBodySize:   5,
+			captureBody:   true,
+			wantBody:      []byte(`{"key`),
+			wantTruncated: true,
+		},
+		{
+			name:        "non positive max body size keeps default",
+			maxBodySize: -5,
+			captureBody: true,
+			wantBody:    []byte(`{"key":"value"}`),
+		},
+		{
+			name:        "body not captured",
+			maxBodySize: service.DefaultMaxBodySize,
+			captureBody: false,
+			wantErr:     true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := repository.NewTaskInMemoryRepository()
+			s := service.NewService(repo, time.Second*30, service.WithMaxBodySize(tt.maxBodySize))
+
+			task := &entity.Task{Method: entity.MethodGet, URL: server.URL, CaptureBody: tt.captureBody}
+			id, err := repo.Create(context.Background(), task)
+			if err != nil {
+				t.Errorf("Expected to create new task result, got %s", err)
+			}
+
+			s.Execute(id, task)
+
+			got, err := s.GetTaskBody(context.Background(), id)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("GetTaskBody() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+			if !reflect.DeepEqual(got.Body, tt.wantBody) {
+				t.Errorf("GetTaskBody() body = %s, want %s", got.Body, tt.wantBody)
+			}
+			if got.BodyTruncated != tt.wantTruncated {
+				t.Errorf("GetTaskBody() truncated = %v, want %v", got.BodyTruncated, tt.wantTruncated)
+			}
+		})
+	}
+}
+
+func TestService_AddTaskQueueFull(t *testing.T) {
+	started := make(chan struct{}, 1)
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		<-release
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	repo := repository.NewTaskInMemoryRepository()
+	s := service.NewService(repo, time.Second*30, service.WithWorkers(1), service.WithQueueSize(1))
+
+	task := &entity.Task{Method: entity.MethodGet, URL: server.URL}
+
+	if _, err := s.AddTask(context.Background(), task); err != nil {
+		t.Fatalf("Expected to add first task, got: %s", err)
+	}
+	<-started
+
+	queuedID, err := s.AddTask(context.Background(), task)
+	if err != nil {
+		t.Fatalf("Expected to queue second task, got: %s", err)
+	}
+
+	if _, err = s.AddTask(context.Background(), task); !errors.Is(err, service.ErrQueueFull) {
+		t.Errorf("Expected %v, got: %v", service.ErrQueueFull, err)
+	}
+
+	res, err := s.GetTaskResult(context.Background(), queuedID)
+	if err != nil {
+		t.Fatalf("Expected to get queued task, got: %s", err)
+	}
+	if res.Status != entity.TaskStatusNew {
+		t.Errorf("Expected queued task to stay new, got: %v", res.Status)
+	}
+
+	close(release)
+	if err = s.Shutdown(context.Background()); err != nil {
+		t.Errorf("Shutdown() error = %v", err)
+	}
+
+	if _, err = s.AddTask(context.Background(), task); !errors.Is(err, service.ErrShutdown) {
+		t.Errorf("Expected %v, got: %v", service.ErrShutdown, err)
+	}
+}
+
+func TestService_Shutdown(t *testing.T) {
+	started := make(chan struct{}, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	repo := repository.NewTaskInMemoryRepository()
+	s := service.NewService(repo, time.Second*30, service.WithWorkers(1))
+
+	task := &entity.Task{Method: entity.MethodGet, URL: server.URL}
+
+	runningID, err := s.AddTask(context.Background(), task)
+	if err != nil {
+		t.Fatalf("Expected to add first task, got: %s", err)
+	}
+	<-started
+
+	queuedID, err := s.AddTask(context.Background(), task)
+	if err != nil {
+		t.Fatalf("Expected to queue second task, got: %s", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	shutdownStarted := time.Now()
+	if err = s.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Shutdown() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if elapsed := time.Since(shutdownStarted); elapsed > time.Second {
+		t.Errorf("Shutdown() took %s, expected running tasks to be cancelled", elapsed)
+	}
+
+	for _, id := range []string{runningID, queuedID} {
+		res, errGet := s.GetTaskResult(context.Background(), id)
+		if errGet != nil {
+			t.Fatalf("Expected to get task result, got: %s", errGet)
+		}
+		if res.Status != entity.TaskStatusError || res.ErrorKind != entity.ErrorKindCancelled {
+			t.Errorf("Expected task %s to be cancelled, got status %v kind %v", id, res.Status, res.ErrorKind)
+		}
+	}
+}
+
+func TestService_ClientQuota(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	repo := repository.NewTaskInMemoryRepository()
+	s := service.NewService(repo, time.Second*30, service.WithClientQuota(1))
+
+	alice := auth.WithClientID(context.Background(), "alice")
+	bob := auth.WithClientID(context.Background(), "bob")
+	newTask := func() *entity.Task {
+		return &entity.Task{Method: entity.MethodGet, URL: server.URL}
+	}
+
+	firstID, err := s.AddTask(alice, newTask())
+	if err != nil {
+		t.Fatalf("Expected to add first task, got: %s", err)
+	}
+
+	if _, err = s.AddTask(alice, newTask()); !errors.Is(err, service.ErrQuotaExceeded) {
+		t.Errorf("Expected %v, got: %v", service.ErrQuotaExceeded, err)
+	}
+
+	if _, err = s.AddTask(bob, newTask()); err != nil {
+		t.Errorf("Expected other client to add task, got: %s", err)
+	}
+
+	if _, err = s.AddTask(context.Background(), newTask()); err != nil {
+		t.Errorf("Expected anonymous task to be unlimited, got: %s", err)
+	}
+
+	if _, err = s.CancelTask(alice, firstID); err != nil {
+		t.Fatalf("Expected to cancel task, got: %s", err)
+	}
+
+	if _, err = s.AddTask(alice, newTask()); err != nil {
+		t.Errorf("Expected cancelled task to free the quota, got: %s", err)
+	}
+
+	close(release)
+	if err = s.Shutdown(context.Background()); err != nil {
+		t.Errorf("Shutdown() error = %v", err)
+	}
+}
+
+func TestService_HostLimits(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		active  int
+		maxSeen int
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		active++
+		maxSeen = max(maxSeen, active)
+		mu.Unlock()
+
+		time.Sleep(20 * time.Millisecond)
+
+		mu.Lock()
+		active--
+		mu.Unlock()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name      string
+		def       service.HostLimit
+		overrides map[string]service.HostLimit
+		want      int
+	}{
+		{"default limit", service.HostLimit{MaxConcurrent: 2}, nil, 2},
+		{"host override", service.HostLimit{MaxConcurrent: 3}, map[string]service.HostLimit{"127.0.0.1": {MaxConcurrent: 1}}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			maxSeen = 0
+
+			repo := repository.NewTaskInMemoryRepository()
+			s := service.NewService(repo, time.Second*30, service.WithHostLimits(tt.def, tt.overrides))
+
+			ids := make([]string, 0, 6)
+			for i := 0; i < cap(ids); i++ {
+				id, err := s.AddTask(context.Background(), &entity.Task{Method: entity.MethodGet, URL: server.URL})
+				if err != nil {
+					t.Fatalf("Expected to add task, got: %s", err)
+				}
+				ids = append(ids, id)
+			}
+
+			for _, id := range ids {
+				res, err := s.WaitTaskResult(context.Background(), id, 5*time.Second)
+				if err != nil {
+					t.Fatalf("Expected to get task result, got: %s", err)
+				}
+				if res.Status != entity.TaskStatusDone {
+					t.Errorf("Expected task to be done, got: %v", res.Status)
+				}
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if maxSeen != tt.want {
+				t.Errorf("Expected at most %d concurrent requests, got: %d", tt.want, maxSeen)
+			}
+		})
+	}
+}
+
+func TestService_HostLimitsDoNotStarveOtherHosts(t *testing.T) {
+	const delay = 300 * time.Millisecond
+
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(delay)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer slow.Close()
+
+	fast := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer fast.Close()
+
+	repo := repository.NewTaskInMemoryRepository()
+	s := service.NewService(repo, time.Second*30,
+		service.WithWorkers(2),
+		service.WithHostLimits(service.HostLimit{}, map[string]service.HostLimit{"127.0.0.1": {MaxConcurrent: 1}}),
+	)
+
+	// Each slow task fits its own timeout, but not the time spent waiting
+	// for the host behind the others.
+	slowTask := &entity.Task{
+		Method:   entity.MethodGet,
+		URL:      slow.URL,
+		Timeouts: &entity.TaskTimeouts{Total: entity.Duration(delay + delay/2)},
+	}
+
+	slowIDs := make([]string, 0, 3)
+	for i := 0; i < cap(slowIDs); i++ {
+		id, err := s.AddTask(context.Background(), slowTask)
+		if err != nil {
+			t.Fatalf("Expected to add task, got: %s", err)
+		}
+		slowIDs = append(slowIDs, id)
+	}
+
+	started := time.Now()
+	fastID, err := s.AddTask(context.Background(), &entity.Task{
+		Method: entity.MethodGet,
+		URL:    strings.Replace(fast.URL, "127.0.0.1", "localhost", 1),
+	})
+	if err != nil {
+		t.Fatalf("Expected to add task, got: %s", err)
+	}
+
+	res, err := s.WaitTaskResult(context.Background(), fastID, 5*time.Second)
+	if err != nil {
+		t.Fatalf("Expected to get task result, got: %s", err)
+	}
+	if res.Status != entity.TaskStatusDone {
+		t.Errorf("Expected task to other host to be done, got: %v (%s)", res.Status, res.Error)
+	}
+	if elapsed := time.Since(started); elapsed >= delay {
+		t.Errorf("Task to other host took %s, expected it not to wait for the limited host", elapsed)
+	}
+
+	for _, id := range slowIDs {
+		res, err = s.WaitTaskResult(context.Background(), id, 5*time.Second)
+		if err != nil {
+			t.Fatalf("Expected to get task result, got: %s", err)
+		}
+		if res.Status != entity.TaskStatusDone {
+			t.Errorf("Expected task waiting for its host to be done, got: %v (%s)", res.Status, res.Error)
+		}
+	}
+}
+
+func TestService_HostLimitsRetryWhileParked(t *testing.T) {
+	const (
+		delay = 200 * time.Millisecond
+		busy  = 3 * time.Second
+	)
+
+	var calls atomic.Int32
+	requested := make(chan struct{}, 1)
+	limited := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		first := r.URL.Path == "/retry" && calls.Add(1) == 1
+		if first {
+			requested <- struct{}{}
+		}
+		time.Sleep(delay)
+		if first {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer limited.Close()
+
+	release := make(chan struct{})
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		case <-time.After(busy):
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer other.Close()
+	defer close(release)
+
+	repo := repository.NewTaskInMemoryRepository()
+	s := service.NewService(repo, time.Second*30,
+		service.WithWorkers(2),
+		service.WithHostLimits(service.HostLimit{}, map[string]service.HostLimit{"127.0.0.1": {MaxConcurrent: 1}}),
+	)
+
+	add := func(task *entity.Task) string {
+		id, err := s.AddTask(context.Background(), task)
+		if err != nil {
+			t.Fatalf("Expected to add task, got: %s", err)
+		}
+		return id
+	}
+
+	started := time.Now()
+	retryID := add(&entity.Task{
+		Method: entity.MethodGet,
+		URL:    limited.URL + "/retry",
+		Retry:  &entity.TaskRetry{MaxAttempts: 2, BackoffBase: entity.Duration(delay / 2)},
+	})
+	<-requested
+
+	// The second task to the limited host is parked while the first one
+	// holds the host, then the other worker is kept busy elsewhere.
+	parkedID := add(&entity.Task{Method: entity.MethodGet, URL: limited.URL})
+	time.Sleep(delay / 4)
+	add(&entity.Task{Method: entity.MethodGet, URL: strings.Replace(other.URL, "127.0.0.1", "localhost", 1)})
+
+	res, err := s.WaitTaskResult(context.Background(), retryID, 5*time.Second)
+	if err != nil {
+		t.Fatalf("Expected to get task result, got: %s", err)
+	}
+	if res.Status != entity.TaskStatusDone || res.AttemptCount != 2 {
+		t.Errorf("Expected retried task to be done after 2 attempts, got: %v after %d (%s)",
+			res.Status, res.AttemptCount, res.Error)
+	}
+	if elapsed := time.Since(started); elapsed >= busy/2 {
+		t.Errorf("Retried task took %s, expected the parked task not to hold its host", elapsed)
+	}
+
+	res, err = s.WaitTaskResult(context.Background(), parkedID, 5*time.Second)
+	if err != nil {
+		t.Fatalf("Expected to get task result, got: %s", err)
+	}
+	if res.Status != entity.TaskStatusDone {
+		t.Errorf("Expected parked task to be done, got: %v (%s)", res.Status, res.Error)
+	}
+}
+
+func TestParseHostLimits(t *testing.T) {
+	tests := []struct {
+		name    string
+		values  []string
+		want    map[string]service.HostLimit
+		wantErr bool
+	}{
+		{"empty", nil, map[string]service.HostLimit{}, false},
+		{
+			"concurrency and rate",
+			[]string{"API.example.com=4/10", "slow.org=1/0.5/2"},
+			map[string]service.HostLimit{
+				"api.example.com": {MaxConcurrent: 4, RPS: 10},
+				"slow.org":        {MaxConcurrent: 1, RPS: 0.5, Burst: 2},
+			},
+			false,
+		},
+		{"missing host", []string{"=1/1"}, nil, true},
+		{"missing rate", []string{"example.com=1"}, nil, true},
+		{"negative limit", []string{"example.com=-1/1"}, nil, true},
+		{"invalid number", []string{"example.com=a/1"}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := service.ParseHostLimits(tt.values)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseHostLimits() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, service.ErrInvalidHostLimit) {
+				t.Errorf("ParseHostLimits() error = %v, want %v", err, service.ErrInvalidHostLimit)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseHostLimits() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestService_Transport(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	repo := repository.NewTaskInMemoryRepository()
+	s := service.NewService(repo, time.Second*30, service.WithTransport(service.TransportConfig{
+		MaxIdleConnsPerHost: 8,
+		DisableHTTP2:        true,
+		TLSMinVersion:       tls.VersionTLS12,
+	}))
+
+	for i := 0; i < 2; i++ {
+		task := &entity.Task{Method: entity.MethodGet, URL: server.URL}
+		id, err := repo.Create(context.Background(), task)
+		if err != nil {
+			t.Fatalf("Expected to create task, got: %s", err)
+		}
+		s.Execute(id, task)
+	}
+
+	stats := s.TransportStats()
+	if stats.Requests != 2 || stats.ConnsOpened != 1 || stats.ReusedConns != 1 {
+		t.Errorf("TransportStats() = %+v, want 2 requests over 1 reused connection", stats)
+	}
+
+	want := entity.TransportSettings{
+		MaxIdleConns:        100,
+		MaxIdleConnsPerHost: 8,
+		IdleConnTimeout:     entity.Duration(90 * time.Second),
+		KeepAlive:           entity.Duration(30 * time.Second),
+		TLSMinVersion:       "TLS 1.2",
+	}
+	if !reflect.DeepEqual(stats.Settings, want) {
+		t.Errorf("TransportStats() settings = %+v, want %+v", stats.Settings, want)
+	}
+}
+
+func TestService_TransportProxy(t *testing.T) {
+	proxied := make(chan string, 1)
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied <- r.URL.String()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer proxy.Close()
+
+	proxyURL, err := url.Parse(proxy.URL)
+	if err != nil {
+		t.Fatalf("Expected to parse proxy url, got: %s", err)
+	}
+
+	tests := []struct {
+		name       string
+		policy     *policy.Policy
+		url        string
+		wantStatus entity.TaskResultStatus
+		wantKind   entity.TaskErrorKind
+	}{
+		{"request sent through proxy", nil, "http://upstream.test/path", entity.TaskStatusDone, 0},
+		{
+			"destination checked before proxy",
+			&policy.Policy{DeniedNets: policy.PrivateNets()},
+			"http://localhost/path",
+			entity.TaskStatusError,
+			entity.ErrorKindDestinationNotAllowed,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := repository.NewTaskInMemoryRepository()
+			s := service.NewService(repo, time.Second*30,
+				service.WithEgressPolicy(tt.policy),
+				service.WithTransport(service.TransportConfig{ProxyURL: proxyURL}),
+			)
+
+			task := &entity.Task{Method: entity.MethodGet, URL: tt.url}
+			id, errCreate := repo.Create(context.Background(), task)
+			if errCreate != nil {
+				t.Fatalf("Expected to create task, got: %s", errCreate)
+			}
+			s.Execute(id, task)
+
+			res, errGet := repo.GetByID(context.Background(), id)
+			if errGet != nil {
+				t.Fatalf("Expected to get task result, got: %s", errGet)
+			}
+			if res.Status != tt.wantStatus || res.ErrorKind != tt.wantKind {
+				t.Errorf("Execute() status = %v, kind = %v (%s), want %v, %v",
+					res.Status, res.ErrorKind, res.Error, tt.wantStatus, tt.wantKind)
+			}
+
+			if tt.wantStatus == entity.TaskStatusDone {
+				if got := <-proxied; got != tt.url {
+					t.Errorf("proxy received %q, want %q", got, tt.url)
+				}
+			}
+		})
+	}
+}
+
+func TestService_CancelTask(t *testing.T) {
+	started := make(chan struct{}, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	repo := repository.NewTaskInMemoryRepository()
+	s := service.NewService(repo, time.Second*30, service.WithWorkers(1))
+
+	task := &entity.Task{Method: entity.MethodGet, URL: server.URL}
+
+	inFlightID, err := s.AddTask(context.Background(), task)
+	if err != nil {
+		t.Fatalf("Expected to add task, got: %s", err)
+	}
+	<-started
+
+	queuedID, err := s.AddTask(context.Background(), task)
+	if err != nil {
+		t.Fatalf("Expected to add task, got: %s", err)
+	}
+
+	for _, id := range []string{queuedID, inFlightID} {
+		res, errCancel := s.CancelTask(context.Background(), id)
+		if errCancel != nil {
+			t.Fatalf("CancelTask() error = %v", errCancel)
+		}
+		if res.Status != entity.TaskStatusCancelled {
+			t.Errorf("CancelTask() status = %v, want %v", res.Status, entity.TaskStatusCancelled)
+		}
+	}
+
+	if err = s.Shutdown(context.Background()); err != nil {
+		t.Errorf("Shutdown() error = %v", err)
+	}
+
+	for _, id := range []string{queuedID, inFlightID} {
+		res, errGet := s.GetTaskResult(context.Background(), id)
+		if errGet != nil {
+			t.Fatalf("GetTaskResult() error = %v", errGet)
+		}
+		if res.Status != entity.TaskStatusCancelled {
+			t.Errorf("GetTaskResult() status = %v, want %v", res.Status, entity.TaskStatusCancelled)
+		}
+	}
+
+	if _, err = s.CancelTask(context.Background(), inFlightID); !errors.Is(err, service.ErrTaskFinished) {
+		t.Errorf("CancelTask() error = %v, want %v", err, service.ErrTaskFinished)
+	}
+
+	if _, err = s.CancelTask(context.Background(), uuid.New().String()); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("CancelTask() error = %v, want %v", err, repository.ErrNotFound)
+	}
+}
+
+func TestService_ExecuteErrors(t *testing.T) {
+	closedServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	closedServer.Close()
+
+	tlsServer := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer tlsServer.Close()
+
+	slowServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer slowServer.Close()
+
+	tests := []struct {
+		name     string
+		url      string
+		wantKind entity.TaskErrorKind
+	}{
+		{"invalid request", "ftp://example.com/file", entity.ErrorKindInvalidRequest},
+		{"connection refused", closedServer.URL, entity.ErrorKindConnectionRefused},
+		{"tls", tlsServer.URL, entity.ErrorKindTLS},
+		{"timeout", slowServer.URL, entity.ErrorKindTimeout},
+		{"dns", "http://aggregator.invalid", entity.ErrorKindDNS},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := repository.NewTaskInMemoryRepository()
+			s := service.NewService(repo, time.Millisecond*200)
+
+			task := &entity.Task{Method: entity.MethodGet, URL: tt.url}
+			id, err := repo.Create(context.Background(), task)
+			if err != nil {
+				t.Fatalf("Expected to create new task result, got %s", err)
+			}
+
+			s.Execute(id, task)
+
+			res, err := repo.GetByID(context.Background(), id)
+			if err != nil {
+				t.Fatalf("expected to get task result, got %s", err)
+			}
+			if res.Status != entity.TaskStatusError {
+				t.Errorf("Execute() status = %v, want %v", res.Status, entity.TaskStatusError)
+			}
+			if res.ErrorKind != tt.wantKind {
+				t.Errorf("Execute() error kind = %v (%s), want %v", res.ErrorKind, res.Error, tt.wantKind)
+			}
+			if res.Error == "" {
+				t.Errorf("Execute() error message is empty")
+			}
+		})
+	}
+}
+
+func TestService_ExecuteRetry(t *testing.T) {
+	tests := []struct {
+		name         string
+		retry        *entity.TaskRetry
+		codes        []int
+		wantCode     int
+		wantAttempts []int
+	}{
+		{
+			name:         "retry until success",
+			retry:        &entity.TaskRetry{MaxAttempts: 3, BackoffBase: entity.Duration(time.Millisecond)},
+			codes:        []int{http.StatusServiceUnavailable, http.StatusBadGateway, http.StatusOK},
+			wantCode:     http.StatusOK,
+			wantAttempts: []int{http.StatusServiceUnavailable, http.StatusBadGateway, http.StatusOK},
+		},
+		{
+			name:         "retry until attempts exhausted",
+			retry:        &entity.TaskRetry{MaxAttempts: 2, BackoffBase: entity.Duration(time.Millisecond)},
+			codes:        []int{http.StatusServiceUnavailable, http.StatusServiceUnavailable, http.StatusOK},
+			wantCode:     http.StatusServiceUnavailable,
+			wantAttempts: []int{http.StatusServiceUnavailable, http.StatusServiceUnavailable},
+		},
+		{
+			name: "retry custom status codes with retry-after",
+			retry: &entity.TaskRetry{
+				MaxAttempts:       3,
+				RetryStatusCodes:  []int{http.StatusConflict},
+				RespectRetryAfter: true,
+			},
+			codes:        []int{http.StatusConflict, http.StatusServiceUnavailable},
+			wantCode:     http.StatusServiceUnavailable,
+			wantAttempts: []int{http.StatusConflict, http.StatusServiceUnavailable},
+		},
+		{
+			name:         "no retry without policy",
+			codes:        []int{http.StatusServiceUnavailable, http.StatusOK},
+			wantCode:     http.StatusServiceUnavailable,
+			wantAttempts: []int{http.StatusServiceUnavailable},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mu := sync.Mutex{}
+			calls := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				code := tt.codes[calls]
+				calls++
+				mu.Unlock()
+
+				w.Header().Set("Retry-After", "0")
+				w.WriteHeader(code)
+			}))
+			defer server.Close()
+
+			repo := repository.NewTaskInMemoryRepository()
+			s := service.NewService(repo, time.Second*30)
+
+			task := &entity.Task{Method: entity.MethodGet, URL: server.URL, Retry: tt.retry}
+			id, err := repo.Create(context.Background(), task)
+			if err != nil {
+				t.Fatalf("Expected to create new task result, got %s", err)
+			}
+
+			s.Execute(id, task)
+
+			res, err := repo.GetByID(context.Background(), id)
+			if err != nil {
+				t.Fatalf("expected to get task result, got %s", err)
+			}
+			if res.Status != entity.TaskStatusDone || res.HTTPStatusCode != tt.wantCode {
+				t.Errorf("Execute() got status %v with code %d, want done with %d", res.Status, res.HTTPStatusCode, tt.wantCode)
+			}
+			if res.AttemptCount != len(tt.wantAttempts) {
+				t.Errorf("Execute() attempt count = %d, want %d", res.AttemptCount, len(tt.wantAttempts))
+			}
+
+			gotAttempts := make([]int, 0, len(res.Attempts))
+			for _, a := range res.Attempts {
+				gotAttempts = append(gotAttempts, a.HTTPStatusCode)
+			}
+			if !reflect.DeepEqual(gotAttempts, tt.wantAttempts) {
+				t.Errorf("Execute() attempts = %v, want %v", gotAttempts, tt.wantAttempts)
+			}
+		})
+	}
+}
+
+func TestService_ExecuteTimeouts(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second * 5):
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name        string
+		opts        []service.Option
+		timeouts    *entity.TaskTimeouts
+		wantMessage string
+	}{
+		{
+			name:     "task total timeout",
+			timeouts: &entity.TaskTimeouts{Total: entity.Duration(time.Millisecond * 50)},
+		},
+		{
+			name:     "task total timeout bounded by server maximum",
+			opts:     []service.Option{service.WithMaxTimeout(time.Millisecond * 50)},
+			timeouts: &entity.TaskTimeouts{Total: entity.Duration(time.Minute)},
+		},
+		{
+			name:        "response header timeout",
+			timeouts:    &entity.TaskTimeouts{ResponseHeader: entity.Duration(time.Millisecond * 50)},
+			wantMessage: "response header timeout after 50ms",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := repository.NewTaskInMemoryRepository()
+			s := service.NewService(repo, time.Second*30, tt.opts...)
+
+			task := &entity.Task{Method: entity.MethodGet, URL: server.URL, Timeouts: tt.timeouts}
+			id, err := repo.Create(context.Background(), task)
+			if err != nil {
+				t.Fatalf("Expected to create new task result, got %s", err)
+			}
+
+			started := time.Now()
+			s.Execute(id, task)
+
+			if elapsed := time.Since(started); elapsed > time.Second {
+				t.Errorf("Execute() took %s, expected the task timeout to apply", elapsed)
+			}
+
+			res, err := repo.GetByID(context.Background(), id)
+			if err != nil {
+				t.Fatalf("expected to get task result, got %s", err)
+			}
+			if res.ErrorKind != entity.ErrorKindTimeout {
+				t.Errorf("Execute() error kind = %v (%s), want %v", res.ErrorKind, res.Error, entity.ErrorKindTimeout)
+			}
+			if tt.wantMessage != "" && !strings.Contains(res.Error, tt.wantMessage) {
+				t.Errorf("Execute() error = %q, want it to contain %q", res.Error, tt.wantMessage)
+			}
+		})
+	}
+}
+
+func TestService_ExecuteRedirects(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/a":
+			http.Redirect(w, r, "/b", http.StatusFound)
+		case "/b":
+			http.Redirect(w, r, "/c", http.StatusMovedPermanently)
+		case "/other-host":
+			http.Redirect(w, r, strings.Replace(server.URL, "127.0.0.1", "localhost", 1)+"/c", http.StatusFound)
+		default:
+			w.WriteHeader(http.StatusOK)
+		}
+	}))
+	defer server.Close()
+
+	hopA := entity.TaskRedirect{URL: server.URL + "/a", StatusCode: http.StatusFound, Location: "/b"}
+	hopB := entity.TaskRedirect{URL: server.URL + "/b", StatusCode: http.StatusMovedPermanently, Location: "/c"}
+
+	tests := []struct {
+		name          string
+		path          string
+		redirect      *entity.TaskRedirectPolicy
+		retry         *entity.TaskRetry
+		wantStatus    entity.TaskResultStatus
+		wantKind      entity.TaskErrorKind
+		wantHTTPCode  int
+		wantRedirects []entity.TaskRedirect
+	}{
+		{
+			name:          "follow by default",
+			path:          "/a",
+			wantStatus:    entity.TaskStatusDone,
+			wantHTTPCode:  http.StatusOK,
+			wantRedirects: []entity.TaskRedirect{hopA, hopB},
+		},
+		{
+			name:          "no follow",
+			path:          "/a",
+			redirect:      &entity.TaskRedirectPolicy{NoFollow: true},
+			wantStatus:    entity.TaskStatusDone,
+			wantHTTPCode:  http.StatusFound,
+			wantRedirects: []entity.TaskRedirect{hopA},
+		},
+		{
+			name:          "max hops",
+			path:          "/a",
+			redirect:      &entity.TaskRedirectPolicy{MaxHops: 1},
+			wantStatus:    entity.TaskStatusError,
+			wantKind:      entity.ErrorKindInvalidRequest,
+			wantRedirects: []entity.TaskRedirect{hopA, hopB},
+		},
+		{
+			name:          "max hops not retried",
+			path:          "/a",
+			redirect:      &entity.TaskRedirectPolicy{MaxHops: 1},
+			retry:         &entity.TaskRetry{MaxAttempts: 3, BackoffBase: entity.Duration(time.Millisecond)},
+			wantStatus:    entity.TaskStatusError,
+			wantKind:      entity.ErrorKindInvalidRequest,
+			wantRedirects: []entity.TaskRedirect{hopA, hopB},
+		},
+		{
+			name:         "same host only",
+			path:         "/other-host",
+			redirect:     &entity.TaskRedirectPolicy{SameHostOnly: true},
+			wantStatus:   entity.TaskStatusDone,
+			wantHTTPCode: http.StatusFound,
+			wantRedirects: []entity.TaskRedirect{{
+				URL:        server.URL + "/other-host",
+				StatusCode: http.StatusFound,
+				Location:   strings.Replace(server.URL, "127.0.0.1", "localhost", 1) + "/c",
+			}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := repository.NewTaskInMemoryRepository()
+			s := service.NewService(repo, time.Second*30)
+
+			task := &entity.Task{Method: entity.MethodGet, URL: server.URL + tt.path, Redirect: tt.redirect, Retry: tt.retry}
+			id, err := repo.Create(context.Background(), task)
+			if err != nil {
+				t.Fatalf("Expected to create new task result, got %s", err)
+			}
+
+			s.Execute(id, task)
+
+			res, err := repo.GetByID(context.Background(), id)
+			if err != nil {
+				t.Fatalf("expected to get task result, got %s", err)
+			}
+			if res.Status != tt.wantStatus {
+				t.Errorf("Execute() status = %v (%s), want %v", res.Status, res.Error, tt.wantStatus)
+			}
+			if res.ErrorKind != tt.wantKind {
+				t.Errorf("Execute() error kind = %v, want %v", res.ErrorKind, tt.wantKind)
+			}
+			if res.AttemptCount > 1 {
+				t.Errorf("Execute() attempts = %d, want a redirect failure not to be retried", res.AttemptCount)
+			}
+			if res.HTTPStatusCode != tt.wantHTTPCode {
+				t.Errorf("Execute() http status = %d, want %d", res.HTTPStatusCode, tt.wantHTTPCode)
+			}
+			if !reflect.DeepEqual(res.Redirects, tt.wantRedirects) {
+				t.Errorf("Execute() redirects = %+v, want %+v", res.Redirects, tt.wantRedirects)
+			}
+		})
+	}
+}
+
+func TestService_Callback(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer upstream.Close()
+
+	tests := []struct {
+		name           string
+		callbackStatus int
+		wantStatus     entity.CallbackStatus
+	}{
+		{"callback delivered", http.StatusNoContent, entity.CallbackStatusDelivered},
+		{"callback failed", http.StatusInternalServerError, entity.CallbackStatusFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var received entity.TaskResult
+			callback := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, err := io.ReadAll(r.Body)
+				if err != nil {
+					t.Errorf("Expected to read callback body, got: %s", err)
+				}
+				if got, want := r.Header.Get(service.HeaderCallbackSignature), service.Sign("secret", body); got != want {
+					t.Errorf("Expected signature %s, got: %s", want, got)
+				}
+				if err = json.Unmarshal(body, &received); err != nil {
+					t.Errorf("Expected to decode callback body, got: %s", err)
+				}
+				w.WriteHeader(tt.callbackStatus)
+			}))
+			defer callback.Close()
+
+			repo := repository.NewTaskInMemoryRepository()
+			s := service.NewService(repo, time.Second*30, service.WithCallbackAttempts(1))
+
+			id, err := s.AddTask(context.Background(), &entity.Task{
+				Method:   entity.MethodGet,
+				URL:      upstream.URL,
+				Callback: &entity.TaskCallback{URL: callback.URL, Secret: "secret"},
+			})
+			if err != nil {
+				t.Fatalf("Expected to add task, got: %s", err)
+			}
+
+			if _, err = s.WaitTaskResult(context.Background(), id, 5*time.Second); err != nil {
+				t.Fatalf("WaitTaskResult() error = %v", err)
+			}
+
+			if err = s.Shutdown(context.Background()); err != nil {
+				t.Fatalf("Shutdown() error = %v", err)
+			}
+
+			if received.ID != id || received.HTTPStatusCode != http.StatusAccepted {
+				t.Errorf("Expected callback with task %s result, got: %+v", id, received)
+			}
+
+			res, err := s.GetTaskResult(context.Background(), id)
+			if err != nil {
+				t.Fatalf("GetTaskResult() error = %v", err)
+			}
+			if res.Callback == nil || res.Callback.Status != tt.wantStatus || res.Callback.Attempts != 1 {
+				t.Errorf("GetTaskResult() callback = %+v, want status %v after 1 attempt", res.Callback, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestService_CallbackShutdown(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer upstream.Close()
+
+	release := make(chan struct{})
+	callback := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer callback.Close()
+	defer close(release)
+
+	repo := repository.NewTaskInMemoryRepository()
+	s := service.NewService(repo, time.Second*30)
+
+	id, err := s.AddTask(context.Background(), &entity.Task{
+		Method:   entity.MethodGet,
+		URL:      upstream.URL,
+		Callback: &entity.TaskCallback{URL: callback.URL},
+	})
+	if err != nil {
+		t.Fatalf("Expected to add task, got: %s", err)
+	}
+
+	if _, err = s.WaitTaskResult(context.Background(), id, 5*time.Second); err != nil {
+		t.Fatalf("WaitTaskResult() error = %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	started := time.Now()
+	if err = s.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Shutdown() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if elapsed := time.Since(started); elapsed > time.Second {
+		t.Errorf("Shutdown() took %s, expected the callback delivery to be cancelled", elapsed)
+	}
+
+	res, err := s.GetTaskResult(context.Background(), id)
+	if err != nil {
+		t.Fatalf("GetTaskResult() error = %v", err)
+	}
+	if res.Callback == nil || res.Callback.Status != entity.CallbackStatusFailed {
+		t.Errorf("GetTaskResult() callback = %+v, want status %v", res.Callback, entity.CallbackStatusFailed)
+	}
+}
+
+func TestService_WaitTaskResult(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	repo := repository.NewTaskInMemoryRepository()
+	s := service.NewService(repo, time.Second*30)
+
+	id, err := s.AddTask(context.Background(), &entity.Task{Method: entity.MethodGet, URL: server.URL})
+	if err != nil {
+		t.Fatalf("Expected to add task, got: %s", err)
+	}
+
+	res, err := s.WaitTaskResult(context.Background(), id, time.Millisecond*50)
+	if err != nil {
+		t.Fatalf("WaitTaskResult() error = %v", err)
+	}
+	if res.Status.Terminal() {
+		t.Errorf("WaitTaskResult() status = %v, want non-terminal after expired wait", res.Status)
+	}
+
+	go func() {
+		time.Sleep(time.Millisecond * 50)
+		close(release)
+	}()
+
+	res, err = s.WaitTaskResult(context.Background(), id, time.Second*10)
+	if err != nil {
+		t.Fatalf("WaitTaskResult() error = %v", err)
+	}
+	if res.Status != entity.TaskStatusDone {
+		t.Errorf("WaitTaskResult() status = %v, want %v", res.Status, entity.TaskStatusDone)
+	}
+	if res.HTTPStatusCode != http.StatusOK {
+		t.Errorf("WaitTaskResult() status code = %d, want %d", res.HTTPStatusCode, http.StatusOK)
+	}
+
+	_, err = s.WaitTaskResult(context.Background(), uuid.New().String(), time.Second)
+	if !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("WaitTaskResult() error = %v, want %v", err, repository.ErrNotFound)
+	}
+
+	if err = s.Shutdown(context.Background()); err != nil {
+		t.Errorf("Shutdown() error = %v", err)
+	}
+}
+
+func TestService_Retention(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	repo := repository.NewTaskInMemoryRepository()
+	s := service.NewService(
+		repo,
+		time.Second*30,
+		service.WithRetention(time.Millisecond*10, 0),
+		service.WithJanitorInterval(time.Millisecond*10),
+	)
+
+	id, err := s.AddTask(context.Background(), &entity.Task{Method: entity.MethodGet, URL: server.URL})
+	if err != nil {
+		t.Fatalf("Expected to add task, got: %s", err)
+	}
+
+	deadline := time.Now().Add(time.Second * 5)
+	for {
+		_, err = s.GetTaskResult(context.Background(), id)
+		if errors.Is(err, repository.ErrNotFound) {
+			break
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected finished task to be evicted, got: %v", err)
+		}
+
+		time.Sleep(time.Millisecond * 10)
+	}
+
+	if err = s.Shutdown(context.Background()); err != nil {
+		t.Errorf("Shutdown() error = %v", err)
+	}
+}
+
+func TestService_DeleteTask(t *testing.T) {
+	repo := repository.NewTaskInMemoryRepository()
+	s := service.NewService(repo, time.Second*30)
+
+	pendingID, err := repo.Create(context.Background(), &entity.Task{})
+	if err != nil {
+		t.Fatalf("Expected to create task, got: %s", err)
+	}
+
+	doneID, err := repo.Create(context.Background(), &entity.Task{})
+	if err != nil {
+		t.Fatalf("Expected to create task, got: %s", err)
+	}
+	if err = repo.Update(context.Background(), &entity.TaskResult{ID: doneID, Status: entity.TaskStatusDone}); err != nil {
+		t.Fatalf("Expected to update task, got: %s", err)
+	}
+
+	notifyingID, err := repo.Create(context.Background(), &entity.Task{})
+	if err != nil {
+		t.Fatalf("Expected to create task, got: %s", err)
+	}
+	err = repo.Update(context.Background(), &entity.TaskResult{
+		ID:       notifyingID,
+		Status:   entity.TaskStatusDone,
+		Callback: &entity.CallbackDelivery{Status: entity.CallbackStatusPending},
+	})
+	if err != nil {
+		t.Fatalf("Expected to update task, got: %s", err)
+	}
+
+	if _, err = s.DeleteTask(context.Background(), pendingID); !errors.Is(err, service.ErrTaskNotFinished) {
+		t.Errorf("DeleteTask() error = %v, want %v", err, service.ErrTaskNotFinished)
+	}
+
+	if _, err = s.DeleteTask(context.Background(), notifyingID); !errors.Is(err, service.ErrCallbackPending) {
+		t.Errorf("DeleteTask() error = %v, want %v", err, service.ErrCallbackPending)
+	}
+
+	if _, err = s.DeleteTask(context.Background(), doneID); err != nil {
+		t.Errorf("DeleteTask() error = %v", err)
+	}
+
+	if _, err = s.DeleteTask(context.Background(), doneID); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("DeleteTask() error = %v, want %v", err, repository.ErrNotFound)
+	}
+}
+
+type recordingMetrics struct {
+	mu       sync.Mutex
+	created  []entity.TaskMethod
+	finished []entity.TaskResultStatus
+	upstream []int
+}
+
+func (m *recordingMetrics) TaskCreated(method entity.TaskMethod) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.created = append(m.created, method)
+}
+
+func (m *recordingMetrics) TaskFinished(status entity.TaskResultStatus) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.finished = append(m.finished, status)
+}
+
+func (m *recordingMetrics) UpstreamRequest(_ entity.TaskMethod, statusCode int, _ time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.upstream = append(m.upstream, statusCode)
+}
+
+func TestService_Metrics(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	m := &recordingMetrics{}
+	s := service.NewService(repository.NewTaskInMemoryRepository(), time.Second*30, service.WithMetrics(m))
+
+	res, err := s.RunTask(context.Background(), &entity.Task{Method: entity.MethodPut, URL: server.URL})
+	if err != nil {
+		t.Fatalf("RunTask() error = %v", err)
+	}
+
+	if err = s.Shutdown(context.Background()); err != nil {
+		t.Errorf("Shutdown() error = %v", err)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !reflect.DeepEqual(m.created, []entity.TaskMethod{entity.MethodPut}) {
+		t.Errorf("TaskCreated() calls = %v", m.created)
+	}
+	if !reflect.DeepEqual(m.finished, []entity.TaskResultStatus{res.Status}) {
+		t.Errorf("TaskFinished() calls = %v, want [%v]", m.finished, res.Status)
+	}
+	if !reflect.DeepEqual(m.upstream, []int{http.StatusAccepted}) {
+		t.Errorf("UpstreamRequest() calls = %v", m.upstream)
+	}
+	if s.InFlight() != 0 || s.QueueDepth() != 0 {
+		t.Errorf("InFlight() = %d, QueueDepth() = %d, want 0", s.InFlight(), s.QueueDepth())
+	}
+}
+
+func TestService_EgressPolicy(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	p := &policy.Policy{
+		DeniedNets: []netip.Prefix{netip.MustParsePrefix("127.0.0.0/8"), netip.MustParsePrefix("::1/128")},
+	}
+
+	repo := repository.NewTaskInMemoryRepository()
+	s := service.NewService(repo, time.Second*30, service.WithEgressPolicy(p))
+
+	_, err := s.AddTask(context.Background(), &entity.Task{Method: entity.MethodGet, URL: server.URL})
+	if !errors.Is(err, policy.ErrDestinationNotAllowed) {
+		t.Errorf("AddTask() error = %v, want %v", err, policy.ErrDestinationNotAllowed)
+	}
+
+	_, err = s.AddTask(context.Background(), &entity.Task{
+		Method:   entity.MethodGet,
+		URL:      "https://example.com",
+		Callback: &entity.TaskCallback{URL: server.URL},
+	})
+	if !errors.Is(err, policy.ErrDestinationNotAllowed) {
+		t.Errorf("AddTask() with callback error = %v, want %v", err, policy.ErrDestinationNotAllowed)
+	}
+
+	// Host names are only resolved when dialing, so the task is accepted and
+	// fails once the resolved loopback address is checked.
+	localURL := strings.Replace(server.URL, "127.0.0.1", "localhost", 1)
+
+	res, err := s.RunTask(context.Background(), &entity.Task{Method: entity.MethodGet, URL: localURL})
+	if err != nil {
+		t.Fatalf("RunTask() error = %v", err)
+	}
+	if res.Status != entity.TaskStatusError || res.ErrorKind != entity.ErrorKindDestinationNotAllowed {
+		t.Errorf("RunTask() got status %v with kind %v, want error with %v",
+			res.Status, res.ErrorKind, entity.ErrorKindDestinationNotAllowed)
+	}
+
+	if err = s.Shutdown(context.Background()); err != nil {
+		t.Errorf("Shutdown() error = %v", err)
+	}
+}
@@ -0,0 +1,158 @@
+package service
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net/http/httptrace"
+	"sync"
+	"time"
+
+	"github.com/Mi7teR/aggregator/internal/task/entity"
+)
+
+const (
+	phaseConnect        = "connect"
+	phaseTLSHandshake   = "tls handshake"
+	phaseResponseHeader = "response header"
+)
+
+type phaseTimeoutError struct {
+	phase   string
+	timeout time.Duration
+}
+
+func (e *phaseTimeoutError) Error() string {
+	return fmt.Sprintf("%s timeout after %s", e.phase, e.timeout)
+}
+
+func (e *phaseTimeoutError) Timeout() bool {
+	return true
+}
+
+func (e *phaseTimeoutError) Temporary() bool {
+	return true
+}
+
+type phaseTimeouts struct {
+	connect        time.Duration
+	tlsHandshake   time.Duration
+	responseHeader time.Duration
+}
+
+func (s *Service) totalTimeout(task *entity.Task) time.Duration {
+	if task.Timeouts == nil || task.Timeouts.Total <= 0 {
+		return s.clampTimeout(s.timeout)
+	}
+
+	return s.clampTimeout(time.Duration(task.Timeouts.Total))
+}
+
+func (s *Service) phaseTimeouts(task *entity.Task) phaseTimeouts {
+	if task.Timeouts == nil {
+		return phaseTimeouts{}
+	}
+
+	return phaseTimeouts{
+		connect:        s.clampTimeout(time.Duration(task.Timeouts.Connect)),
+		tlsHandshake:   s.clampTimeout(time.Duration(task.Timeouts.TLSHandshake)),
+		responseHeader: s.clampTimeout(time.Duration(task.Timeouts.ResponseHeader)),
+	}
+}
+
+func (s *Service) clampTimeout(d time.Duration) time.Duration {
+	if d > s.maxTimeout {
+		return s.maxTimeout
+	}
+
+	return d
+}
+
+type phaseWatch struct {
+	mu     sync.Mutex
+	cancel context.CancelCauseFunc
+	timers map[string]*time.Timer
+}
+
+// withPhaseTimeouts returns a context that is cancelled with a
+// phaseTimeoutError once a connection phase outlives its timeout.
+func withPhaseTimeouts(ctx context.Context, t phaseTimeouts) (context.Context, func()) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	w := &phaseWatch{cancel: cancel, timers: make(map[string]*time.Timer)}
+
+	trace := &httptrace.ClientTrace{
+		ConnectStart: func(_, _ string) {
+			w.start(phaseConnect, t.connect)
+		},
+		ConnectDone: func(_, _ string, err error) {
+			if err == nil {
+				w.stop(phaseConnect)
+			}
+		},
+		TLSHandshakeStart: func() {
+			w.start(phaseTLSHandshake, t.tlsHandshake)
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			w.stop(phaseTLSHandshake)
+		},
+		WroteRequest: func(httptrace.WroteRequestInfo) {
+			w.start(phaseResponseHeader, t.responseHeader)
+		},
+		GotFirstResponseByte: func() {
+			w.stop(phaseResponseHeader)
+		},
+	}
+
+	return httptrace.WithClientTrace(ctx, trace), w.close
+}
+
+func (w *phaseWatch) start(phase string, timeout time.Duration) {
+	if timeout <= 0 {
+		return
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if _, ok := w.timers[phase]; ok || w.timers == nil {
+		return
+	}
+
+	w.timers[phase] = time.AfterFunc(timeout, func() {
+		w.cancel(&phaseTimeoutError{phase: phase, timeout: timeout})
+	})
+}
+
+func (w *phaseWatch) stop(phase string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if timer, ok := w.timers[phase]; ok {
+		timer.Stop()
+		delete(w.timers, phase)
+	}
+}
+
+func (w *phaseWatch) close() {
+	w.mu.Lock()
+	for _, timer := range w.timers {
+		timer.Stop()
+	}
+	w.timers = nil
+	w.mu.Unlock()
+
+	w.cancel(nil)
+}
+
+type closeHookBody struct {
+	io.ReadCloser
+	hook func()
+}
+
+func (b *closeHookBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.hook()
+
+	return err
+}
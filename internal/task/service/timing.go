@@ -0,0 +1,121 @@
+package service
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http/httptrace"
+	"sync"
+	"time"
+
+	"github.com/Mi7teR/aggregator/internal/task/entity"
+)
+
+// timingTrace measures the phases of a single attempt. When the attempt
+// follows redirects the phases describe its final request only, while Total
+// runs from the first request until the response body is closed or the
+// attempt fails.
+type timingTrace struct {
+	mu           sync.Mutex
+	start        time.Time
+	requestStart time.Time
+	dnsStart     time.Time
+	connectStart time.Time
+	tlsStart     time.Time
+	timing       entity.TaskTiming
+	finished     bool
+}
+
+func newTimingTrace() *timingTrace {
+	now := time.Now()
+
+	return &timingTrace{start: now, requestStart: now}
+}
+
+func (t *timingTrace) withContext(ctx context.Context) context.Context {
+	return httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		GetConn: func(string) {
+			t.startRequest()
+		},
+		DNSStart: func(httptrace.DNSStartInfo) {
+			t.mark(&t.dnsStart)
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			t.measure(&t.timing.DNS, &t.dnsStart)
+		},
+		ConnectStart: func(_, _ string) {
+			t.mark(&t.connectStart)
+		},
+		ConnectDone: func(_, _ string, err error) {
+			if err == nil {
+				t.measure(&t.timing.Connect, &t.connectStart)
+			}
+		},
+		TLSHandshakeStart: func() {
+			t.mark(&t.tlsStart)
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			t.measure(&t.timing.TLSHandshake, &t.tlsStart)
+		},
+		GotFirstResponseByte: func() {
+			t.measure(&t.timing.TimeToFirstByte, &t.requestStart)
+		},
+	})
+}
+
+// startRequest drops the phases measured for a previous request of the
+// attempt, so redirects do not stretch them over several hops.
+func (t *timingTrace) startRequest() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.requestStart = time.Now()
+	t.dnsStart, t.connectStart, t.tlsStart = time.Time{}, time.Time{}, time.Time{}
+	t.timing = entity.TaskTiming{}
+}
+
+// mark records the start of a phase. Dialing several addresses reports
+// multiple starts, the first one wins.
+func (t *timingTrace) mark(at *time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if at.IsZero() {
+		*at = time.Now()
+	}
+}
+
+func (t *timingTrace) measure(d *entity.Duration, since *time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if !since.IsZero() {
+		*d = entity.Duration(time.Since(*since))
+	}
+}
+
+func (t *timingTrace) finish() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if !t.finished {
+		t.finished = true
+		t.timing.Total = entity.Duration(time.Since(t.start))
+	}
+}
+
+func (t *timingTrace) result() *entity.TaskTiming {
+	t.finish()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	timing := t.timing
+
+	return &timing
+}
+
+func withTiming(result *entity.TaskResult, trace *timingTrace) *entity.TaskResult {
+	result.Timing = trace.result()
+
+	return result
+}
@@ -0,0 +1,152 @@
+package service
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/Mi7teR/aggregator/internal/policy"
+	"github.com/Mi7teR/aggregator/internal/task/entity"
+)
+
+const (
+	dialTimeout   = 30 * time.Second
+	dialKeepAlive = 30 * time.Second
+	maxRedirects  = entity.MaxRedirectHops
+)
+
+var ErrTooManyRedirects = errors.New("too many redirects")
+
+// TransportConfig tunes the HTTP transport shared by task and callback
+// requests. Zero values keep the net/http defaults.
+type TransportConfig struct {
+	MaxIdleConns        int
+	MaxIdleConnsPerHost int
+	MaxConnsPerHost     int
+	IdleConnTimeout     time.Duration
+	// KeepAlive is the TCP keep-alive period, a negative value disables it.
+	KeepAlive         time.Duration
+	DisableKeepAlives bool
+	DisableHTTP2      bool
+	TLSMinVersion     uint16
+	// ProxyURL sends all requests through the proxy. Without it the proxy
+	// environment variables are used unless an egress policy is set.
+	ProxyURL *url.URL
+}
+
+// newTransport returns the transport shared by task and callback requests.
+// With an egress policy every dialed address is checked. Requests through a
+// configured proxy dial the proxy instead, so their destination is resolved
+// and checked before the request is handed to the proxy, and proxies from
+// the environment are ignored.
+func newTransport(p *policy.Policy, cfg TransportConfig, stats *transportStats) *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+
+	keepAlive := cfg.KeepAlive
+	if keepAlive == 0 {
+		keepAlive = dialKeepAlive
+	}
+
+	dialer := &net.Dialer{
+		Timeout:   dialTimeout,
+		KeepAlive: keepAlive,
+	}
+
+	switch {
+	case cfg.ProxyURL != nil && p != nil:
+		proxyURL := cfg.ProxyURL
+		t.Proxy = func(req *http.Request) (*url.URL, error) {
+			if err := p.CheckResolved(req.Context(), req.URL); err != nil {
+				return nil, err
+			}
+
+			return proxyURL, nil
+		}
+	case cfg.ProxyURL != nil:
+		t.Proxy = http.ProxyURL(cfg.ProxyURL)
+	case p != nil:
+		dialer.Control = p.Control
+		t.Proxy = nil
+	}
+
+	t.DialContext = stats.dialContext(dialer.DialContext)
+
+	if cfg.MaxIdleConns > 0 {
+		t.MaxIdleConns = cfg.MaxIdleConns
+	}
+	if cfg.MaxIdleConnsPerHost > 0 {
+		t.MaxIdleConnsPerHost = cfg.MaxIdleConnsPerHost
+	}
+	if cfg.IdleConnTimeout > 0 {
+		t.IdleConnTimeout = cfg.IdleConnTimeout
+	}
+	t.MaxConnsPerHost = cfg.MaxConnsPerHost
+	t.DisableKeepAlives = cfg.DisableKeepAlives
+
+	if cfg.DisableHTTP2 {
+		t.ForceAttemptHTTP2 = false
+		t.TLSNextProto = make(map[string]func(string, *tls.Conn) http.RoundTripper)
+	}
+
+	if cfg.TLSMinVersion != 0 {
+		t.TLSClientConfig = &tls.Config{MinVersion: cfg.TLSMinVersion}
+	}
+
+	return t
+}
+
+func (s *Service) checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) > maxRedirects {
+		return fmt.Errorf("%w: stopped after %d", ErrTooManyRedirects, maxRedirects)
+	}
+
+	if s.policy == nil {
+		return nil
+	}
+
+	return s.policy.CheckURL(req.URL)
+}
+
+// checkDestinations rejects tasks whose URL or callback URL is denied by the
+// egress policy before they are stored. Addresses behind host names are
+// checked again when dialing.
+func (s *Service) checkDestinations(task *entity.Task) error {
+	if s.policy == nil {
+		return nil
+	}
+
+	destinations := []struct {
+		field string
+		url   string
+	}{
+		{"url", task.URL},
+	}
+	if hasCallback(task) {
+		destinations = append(destinations, struct {
+			field string
+			url   string
+		}{"callback.url", task.Callback.URL})
+	}
+
+	v := &entity.ValidationError{}
+	for _, d := range destinations {
+		u, err := url.Parse(d.url)
+		if err != nil {
+			continue
+		}
+
+		if err = s.policy.CheckURL(u); err != nil {
+			v.Fields = append(v.Fields, entity.FieldError{Field: d.field, Message: err.Error(), Err: err})
+		}
+	}
+
+	if len(v.Fields) > 0 {
+		return v
+	}
+
+	return nil
+}
@@ -0,0 +1,102 @@
+package service
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"sync/atomic"
+
+	"github.com/Mi7teR/aggregator/internal/task/entity"
+)
+
+// transportStats counts the connections of the shared transport, which
+// net/http does not expose itself.
+type transportStats struct {
+	opened   atomic.Int64
+	closed   atomic.Int64
+	requests atomic.Int64
+	reused   atomic.Int64
+}
+
+type dialFunc func(ctx context.Context, network, address string) (net.Conn, error)
+
+func (st *transportStats) dialContext(dial dialFunc) dialFunc {
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		conn, err := dial(ctx, network, address)
+		if err != nil {
+			return nil, err
+		}
+
+		st.opened.Add(1)
+
+		return &countedConn{Conn: conn, stats: st}, nil
+	}
+}
+
+// withContext counts the request and whether it reused a pooled connection.
+func (st *transportStats) withContext(ctx context.Context) context.Context {
+	return httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			st.requests.Add(1)
+			if info.Reused {
+				st.reused.Add(1)
+			}
+		},
+	})
+}
+
+type countedConn struct {
+	net.Conn
+	stats *transportStats
+	once  sync.Once
+}
+
+func (c *countedConn) Close() error {
+	c.once.Do(func() {
+		c.stats.closed.Add(1)
+	})
+
+	return c.Conn.Close()
+}
+
+// TransportStats reports the connection counters and the effective settings
+// of the shared transport.
+func (s *Service) TransportStats() *entity.TransportStats {
+	opened, closed := s.transportStats.opened.Load(), s.transportStats.closed.Load()
+
+	t := s.transport
+	settings := entity.TransportSettings{
+		MaxIdleConns:        t.MaxIdleConns,
+		MaxIdleConnsPerHost: t.MaxIdleConnsPerHost,
+		MaxConnsPerHost:     t.MaxConnsPerHost,
+		IdleConnTimeout:     entity.Duration(t.IdleConnTimeout),
+		KeepAlive:           entity.Duration(s.transportConfig.KeepAlive),
+		DisableKeepAlives:   t.DisableKeepAlives,
+		HTTP2:               !s.transportConfig.DisableHTTP2,
+	}
+
+	if settings.MaxIdleConnsPerHost == 0 {
+		settings.MaxIdleConnsPerHost = http.DefaultMaxIdleConnsPerHost
+	}
+	if settings.KeepAlive == 0 {
+		settings.KeepAlive = entity.Duration(dialKeepAlive)
+	}
+	if v := s.transportConfig.TLSMinVersion; v != 0 {
+		settings.TLSMinVersion = tls.VersionName(v)
+	}
+	if u := s.transportConfig.ProxyURL; u != nil {
+		settings.Proxy = u.Redacted()
+	}
+
+	return &entity.TransportStats{
+		OpenConns:   opened - closed,
+		ConnsOpened: opened,
+		ConnsClosed: closed,
+		Requests:    s.transportStats.requests.Load(),
+		ReusedConns: s.transportStats.reused.Load(),
+		Settings:    settings,
+	}
+}